@@ -7,149 +7,167 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-samples/test-application/go/apputil"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
 )
 
-func main() {
-	log.Println("============ application-golang starts ============")
-
-	err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
-	if err != nil {
-		log.Fatalf("Error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v", err)
-	}
+// assetEventFilter matches the names of the events emitted by the chaincode on asset changes
+const assetEventFilter = "^Asset(Created|Updated|Transferred|Deleted)$"
 
-	wallet, err := gateway.NewFileSystemWallet("wallet")
-	if err != nil {
-		log.Fatalf("Failed to create wallet: %v", err)
-	}
+const usage = `Usage: go run . [flags] [command] [arguments]
 
-	if !wallet.Exists("appUser") {
-		err = populateWallet(wallet)
-		if err != nil {
-			log.Fatalf("Failed to populate wallet contents: %v", err)
-		}
-	}
+Commands:
+  init                                              create the initial set of assets
+  create <id> <color> <size> <owner> <value>        create an asset
+  read <id>                                         print an asset
+  update <id> <color> <size> <owner> <value>        update an asset
+  transfer <id> <owner>                             transfer an asset to a new owner
+  delete <id>                                       delete an asset
+  list                                              print all the assets
+  demo                                              run the sample transactions (the default)
 
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"connection-org1.yaml",
-	)
+Flags:
+`
 
-	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
-	)
-	if err != nil {
-		log.Fatalf("Failed to connect to gateway: %v", err)
+func main() {
+	channel := flag.String("channel", envOrDefault("CHANNEL_NAME", apputil.DefaultChannel), "channel name, or set CHANNEL_NAME")
+	chaincode := flag.String("chaincode", envOrDefault("CHAINCODE_NAME", "basic"), "chaincode name, or set CHAINCODE_NAME")
+	identity := flag.String("identity", envOrDefault("IDENTITY", apputil.DefaultIdentity), "label of the wallet identity, or set IDENTITY")
+	profile := flag.String("profile", envOrDefault("CONNECTION_PROFILE", apputil.DefaultConnectionProfile), "connection profile path, or set CONNECTION_PROFILE")
+	walletPath := flag.String("wallet", envOrDefault("WALLET_PATH", apputil.DefaultWalletPath), "wallet directory, or set WALLET_PATH")
+	mspPath := flag.String("msp-path", envOrDefault("MSP_PATH", apputil.DefaultMSPPath), "MSP directory used to add the identity to the wallet when it is missing, or set MSP_PATH")
+	mspID := flag.String("msp-id", envOrDefault("MSP_ID", apputil.DefaultMSPID), "MSP ID of the identity added to the wallet, or set MSP_ID")
+	output := flag.String("output", envOrDefault("OUTPUT", jsonOutput), "output format of the results, json or table, or set OUTPUT")
+	listen := flag.Bool("listen", false, "listen for asset events and print them, instead of running a command")
+	pageSize := flag.Int("page-size", 0, "page through all assets with GetAssetsPage, this many assets at a time, instead of calling GetAllAssets")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
 	}
-	defer gw.Close()
+	flag.Parse()
 
-	network, err := gw.GetNetwork("mychannel")
-	if err != nil {
-		log.Fatalf("Failed to get network: %v", err)
+	if *output != jsonOutput && *output != tableOutput {
+		log.Fatalf("Invalid output format %q, use %s or %s", *output, jsonOutput, tableOutput)
 	}
 
-	contract := network.GetContract("basic")
-
-	log.Println("--> Submit Transaction: InitLedger, function creates the initial set of assets on the ledger")
-	result, err := contract.SubmitTransaction("InitLedger")
-	if err != nil {
-		log.Fatalf("Failed to Submit transaction: %v", err)
+	command, args := demoCommand, []string(nil)
+	if flag.NArg() > 0 {
+		command, args = flag.Arg(0), flag.Args()[1:]
 	}
-	log.Println(string(result))
-
-	log.Println("--> Evaluate Transaction: GetAllAssets, function returns all the current assets on the ledger")
-	result, err = contract.EvaluateTransaction("GetAllAssets")
-	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v", err)
+	run, ok := commands[command]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
 	}
-	log.Println(string(result))
 
-	log.Println("--> Submit Transaction: CreateAsset, creates new asset with ID, color, owner, size, and appraisedValue arguments")
-	result, err = contract.SubmitTransaction("CreateAsset", "asset13", "yellow", "5", "Tom", "1300")
-	if err != nil {
-		log.Fatalf("Failed to Submit transaction: %v", err)
-	}
-	log.Println(string(result))
+	log.Println("============ application-golang starts ============")
 
-	log.Println("--> Evaluate Transaction: ReadAsset, function returns an asset with a given assetID")
-	result, err = contract.EvaluateTransaction("ReadAsset", "asset13")
+	connection, err := apputil.Connect(
+		apputil.WithConnectionProfile(*profile),
+		apputil.WithWallet(*walletPath),
+		apputil.WithIdentity(*identity, *mspID),
+		apputil.WithMSPDirectory(*mspPath),
+		apputil.WithChannel(*channel),
+		apputil.WithChaincode(*chaincode),
+	)
 	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v\n", err)
+		log.Fatalf("Failed to connect to the contract: %v", err)
 	}
-	log.Println(string(result))
+	defer connection.Close()
+	contract := connection.Contract
 
-	log.Println("--> Evaluate Transaction: AssetExists, function returns 'true' if an asset with given assetID exist")
-	result, err = contract.EvaluateTransaction("AssetExists", "asset1")
-	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v\n", err)
+	if *listen {
+		err = listenForAssetEvents(contract)
+		if err != nil {
+			log.Fatalf("Failed to listen for events: %v", err)
+		}
+		return
 	}
-	log.Println(string(result))
 
-	log.Println("--> Submit Transaction: TransferAsset asset1, transfer to new owner of Tom")
-	_, err = contract.SubmitTransaction("TransferAsset", "asset1", "Tom")
+	cli := &assetTransferCLI{contract: contract, output: *output, pageSize: *pageSize}
+	err = run(cli, args)
 	if err != nil {
-		log.Fatalf("Failed to Submit transaction: %v", err)
+		log.Fatalf("Failed to run %s: %v", command, err)
 	}
+	log.Println("============ application-golang ends ============")
+}
 
-	log.Println("--> Evaluate Transaction: ReadAsset, function returns 'asset1' attributes")
-	result, err = contract.EvaluateTransaction("ReadAsset", "asset1")
-	if err != nil {
-		log.Fatalf("Failed to evaluate transaction: %v", err)
+// envOrDefault returns the value of an environment variable, or a default value when it is not set
+func envOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
 	}
-	log.Println(string(result))
-	log.Println("============ application-golang ends ============")
+	return defaultValue
 }
 
-func populateWallet(wallet *gateway.Wallet) error {
-	log.Println("============ Populating wallet ============")
-	credPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"users",
-		"User1@org1.example.com",
-		"msp",
-	)
+// assetsPage is a page of assets returned by GetAssetsPage
+type assetsPage struct {
+	Records             []json.RawMessage `json:"records"`
+	FetchedRecordsCount int32             `json:"fetchedRecordsCount"`
+	Bookmark            string            `json:"bookmark"`
+}
 
-	certPath := filepath.Join(credPath, "signcerts", "cert.pem")
-	// read the certificate pem
-	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
-	if err != nil {
-		return err
+// fetchAssetPages returns all the current assets on the ledger, fetching them one page at a time
+func fetchAssetPages(contract *gateway.Contract, pageSize int) ([]json.RawMessage, error) {
+	var records []json.RawMessage
+	bookmark := ""
+	for pageNumber := 1; ; pageNumber++ {
+		log.Printf("--> Evaluate Transaction: GetAssetsPage, function returns page %d of the current assets on the ledger", pageNumber)
+		result, err := contract.EvaluateTransaction("GetAssetsPage", strconv.Itoa(pageSize), bookmark)
+		if err != nil {
+			return nil, err
+		}
+
+		var page assetsPage
+		err = json.Unmarshal(result, &page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal page: %v", err)
+		}
+		records = append(records, page.Records...)
+
+		// The last page is not full, or has no bookmark to continue from
+		if int(page.FetchedRecordsCount) < pageSize || page.Bookmark == "" {
+			return records, nil
+		}
+		bookmark = page.Bookmark
 	}
+}
 
-	keyDir := filepath.Join(credPath, "keystore")
-	// there's a single file in this dir containing the private key
-	files, err := ioutil.ReadDir(keyDir)
+// listenForAssetEvents prints the asset events of the chaincode until the program is interrupted
+func listenForAssetEvents(contract *gateway.Contract) error {
+	registration, events, err := contract.RegisterEvent(assetEventFilter)
 	if err != nil {
 		return err
 	}
-	if len(files) != 1 {
-		return fmt.Errorf("keystore folder should have contain one file")
-	}
-	keyPath := filepath.Join(keyDir, files[0].Name())
-	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
-	if err != nil {
-		return err
+	defer contract.Unregister(registration)
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	log.Println("--> Listening for asset events, press Ctrl+C to stop")
+	for {
+		select {
+		case event := <-events:
+			var payload bytes.Buffer
+			err = json.Indent(&payload, event.Payload, "", "  ")
+			if err != nil {
+				// Print payloads that are not JSON as they are
+				payload.Reset()
+				payload.Write(event.Payload)
+			}
+			log.Printf("<-- Event %s in block %d, transaction %s:\n%s", event.EventName, event.BlockNumber, event.TxID, payload.String())
+		case <-interrupt:
+			log.Println("============ application-golang ends ============")
+			return nil
+		}
 	}
-
-	identity := gateway.NewX509Identity("Org1MSP", string(cert), string(key))
-
-	return wallet.Put("appUser", identity)
 }
@@ -0,0 +1,249 @@
+/*
+Copyright 2020 IBM All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/test-application/go/apputil"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// Output formats of the command results
+const (
+	jsonOutput  = "json"
+	tableOutput = "table"
+)
+
+const demoCommand = "demo"
+
+// idempotentTransactions are the transactions that leave the ledger in the same state when they are
+// submitted again after they were committed, and so can be retried after a timeout
+var idempotentTransactions = map[string]bool{
+	"InitLedger": true,
+}
+
+// commands are the subcommands of the application, by name
+var commands = map[string]func(cli *assetTransferCLI, args []string) error{
+	"init":      (*assetTransferCLI).initLedger,
+	"create":    (*assetTransferCLI).createAsset,
+	"read":      (*assetTransferCLI).readAsset,
+	"update":    (*assetTransferCLI).updateAsset,
+	"transfer":  (*assetTransferCLI).transferAsset,
+	"delete":    (*assetTransferCLI).deleteAsset,
+	"list":      (*assetTransferCLI).listAssets,
+	demoCommand: (*assetTransferCLI).demo,
+}
+
+// assetTransferCLI runs the commands of the application against the asset-transfer-basic contract
+type assetTransferCLI struct {
+	contract *gateway.Contract
+	output   string
+	pageSize int
+}
+
+// asset is the asset returned by the contract, with the fields printed in table output
+type asset struct {
+	ID             string `json:"ID"`
+	Color          string `json:"color"`
+	Size           int    `json:"size"`
+	Owner          string `json:"owner"`
+	AppraisedValue int    `json:"appraisedValue"`
+	Version        int    `json:"version"`
+}
+
+func (cli *assetTransferCLI) initLedger(args []string) error {
+	if err := checkArgs(args); err != nil {
+		return err
+	}
+
+	log.Println("--> Submit Transaction: InitLedger, function creates the initial set of assets on the ledger")
+	return cli.submit("InitLedger")
+}
+
+func (cli *assetTransferCLI) createAsset(args []string) error {
+	if err := checkArgs(args, "id", "color", "size", "owner", "appraisedValue"); err != nil {
+		return err
+	}
+
+	log.Printf("--> Submit Transaction: CreateAsset, creates new asset %s", args[0])
+	return cli.submit("CreateAsset", args...)
+}
+
+func (cli *assetTransferCLI) readAsset(args []string) error {
+	if err := checkArgs(args, "id"); err != nil {
+		return err
+	}
+
+	log.Printf("--> Evaluate Transaction: ReadAsset, function returns asset %s", args[0])
+	result, err := cli.contract.EvaluateTransaction("ReadAsset", args[0])
+	if err != nil {
+		return err
+	}
+
+	return cli.printAssets([]json.RawMessage{result})
+}
+
+func (cli *assetTransferCLI) updateAsset(args []string) error {
+	if err := checkArgs(args, "id", "color", "size", "owner", "appraisedValue"); err != nil {
+		return err
+	}
+
+	log.Printf("--> Submit Transaction: UpdateAsset, updates asset %s", args[0])
+	return cli.submit("UpdateAsset", args...)
+}
+
+func (cli *assetTransferCLI) transferAsset(args []string) error {
+	if err := checkArgs(args, "id", "newOwner"); err != nil {
+		return err
+	}
+
+	log.Printf("--> Submit Transaction: TransferAsset %s, transfer to new owner of %s", args[0], args[1])
+	return cli.submit("TransferAsset", args...)
+}
+
+func (cli *assetTransferCLI) deleteAsset(args []string) error {
+	if err := checkArgs(args, "id"); err != nil {
+		return err
+	}
+
+	log.Printf("--> Submit Transaction: DeleteAsset, deletes asset %s", args[0])
+	return cli.submit("DeleteAsset", args[0])
+}
+
+// listAssets prints all the current assets on the ledger, with GetAllAssets or one page at a
+// time with GetAssetsPage when a page size is set
+func (cli *assetTransferCLI) listAssets(args []string) error {
+	if err := checkArgs(args); err != nil {
+		return err
+	}
+
+	if cli.pageSize > 0 {
+		records, err := fetchAssetPages(cli.contract, cli.pageSize)
+		if err != nil {
+			return err
+		}
+		return cli.printAssets(records)
+	}
+
+	log.Println("--> Evaluate Transaction: GetAllAssets, function returns all the current assets on the ledger")
+	result, err := cli.contract.EvaluateTransaction("GetAllAssets")
+	if err != nil {
+		return err
+	}
+
+	var records []json.RawMessage
+	// GetAllAssets returns an empty result rather than an empty array when there are no assets
+	if len(result) > 0 {
+		err = json.Unmarshal(result, &records)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal assets: %v", err)
+		}
+	}
+
+	return cli.printAssets(records)
+}
+
+// demo runs the sample transactions
+func (cli *assetTransferCLI) demo(args []string) error {
+	if err := checkArgs(args); err != nil {
+		return err
+	}
+
+	steps := []struct {
+		command func(cli *assetTransferCLI, args []string) error
+		args    []string
+	}{
+		{(*assetTransferCLI).initLedger, nil},
+		{(*assetTransferCLI).listAssets, nil},
+		{(*assetTransferCLI).createAsset, []string{"asset13", "yellow", "5", "Tom", "1300"}},
+		{(*assetTransferCLI).readAsset, []string{"asset13"}},
+		{(*assetTransferCLI).transferAsset, []string{"asset1", "Tom"}},
+		{(*assetTransferCLI).readAsset, []string{"asset1"}},
+	}
+	for _, step := range steps {
+		if err := step.command(cli, step.args); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// submit submits a transaction, retrying it when it fails with an MVCC read conflict or an
+// endorsement mismatch. A transaction that timed out may still have been committed, so only
+// idempotent transactions are retried after a timeout; for the others, the outcome is reported as unknown.
+func (cli *assetTransferCLI) submit(name string, args ...string) error {
+	retryPolicy := apputil.DefaultRetryPolicy
+	retryPolicy.RetryTimeouts = idempotentTransactions[name]
+	retryPolicy.OnRetry = func(name string, attempt int, class apputil.ErrorClass, err error, backoff time.Duration) {
+		log.Printf("Attempt %d of %s failed with %s, retrying in %v: %v", attempt, name, class, backoff, err)
+	}
+
+	outcome := retryPolicy.Submit(cli.contract, name, args...)
+	if outcome.Class == apputil.Timeout && !retryPolicy.RetryTimeouts {
+		return fmt.Errorf("outcome of transaction %s is unknown, it timed out and may have been committed, check with the read command: %v", name, outcome.Err)
+	}
+	if outcome.Err != nil {
+		return fmt.Errorf("failed to submit transaction after %d attempts, %s: %v", outcome.Attempts, outcome.Class, outcome.Err)
+	}
+
+	return nil
+}
+
+// printAssets prints assets to the standard output, in the output format of the application
+func (cli *assetTransferCLI) printAssets(records []json.RawMessage) error {
+	if cli.output == tableOutput {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "ID\tCOLOR\tSIZE\tOWNER\tAPPRAISED VALUE\tVERSION")
+		for _, record := range records {
+			var a asset
+			err := json.Unmarshal(record, &a)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal asset: %v", err)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%d\t%d\n", a.ID, a.Color, a.Size, a.Owner, a.AppraisedValue, a.Version)
+		}
+		return writer.Flush()
+	}
+
+	for _, record := range records {
+		var indented bytes.Buffer
+		err := json.Indent(&indented, record, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format asset: %v", err)
+		}
+		fmt.Println(indented.String())
+	}
+
+	return nil
+}
+
+// checkArgs checks that a command got one argument for each of the given names.
+// The size and appraisedValue arguments must be integers.
+func checkArgs(args []string, names ...string) error {
+	if len(args) != len(names) {
+		return fmt.Errorf("expected %d arguments %v, got %d", len(names), names, len(args))
+	}
+
+	for i, name := range names {
+		if name == "size" || name == "appraisedValue" {
+			if _, err := strconv.Atoi(args[i]); err != nil {
+				return fmt.Errorf("%s must be an integer, got %q", name, args[i])
+			}
+		}
+	}
+
+	return nil
+}
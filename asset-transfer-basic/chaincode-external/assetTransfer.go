@@ -5,20 +5,18 @@ SPDX-License-Identifier: Apache-2.0
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-external/serverconfig"
 )
 
-type serverConfig struct {
-	CCID    string
-	Address string
-}
-
 // SmartContract provides functions for managing an asset
 type SmartContract struct {
 	contractapi.Contract
@@ -208,28 +206,79 @@ func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface
 }
 
 func main() {
-	// See chaincode.env.example
-	config := serverConfig{
-		CCID:    os.Getenv("CHAINCODE_ID"),
-		Address: os.Getenv("CHAINCODE_SERVER_ADDRESS"),
+	// See chaincode.env
+	config, err := serverconfig.FromEnv()
+	if err != nil {
+		logFatal("config_invalid", err)
+	}
+
+	smartContract := &SmartContract{}
+	metrics := newChaincodeMetrics(smartContract)
+	smartContract.TransactionContextHandler = new(metricsTransactionContext)
+	smartContract.BeforeTransaction = metrics.beforeTransaction
+	smartContract.AfterTransaction = metrics.afterTransaction
+
+	chaincode, err := contractapi.NewChaincode(smartContract)
+
+	if err != nil {
+		logFatal("chaincode_create_failed", err)
 	}
 
-	chaincode, err := contractapi.NewChaincode(&SmartContract{})
+	// CHAINCODE_HTTP_ADDRESS optionally enables the /healthz, /readyz and /metrics endpoints
+	status := &serverStatus{}
+	var httpServer *http.Server
+	if httpAddress := os.Getenv("CHAINCODE_HTTP_ADDRESS"); httpAddress != "" {
+		httpServer = &http.Server{Addr: httpAddress, Handler: newMonitoringHandler(metrics, status)}
+		go func() {
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logFatal("http_server_failed", err)
+			}
+		}()
+	}
 
+	server, err := newChaincodeServer(config, &instrumentedChaincode{Chaincode: chaincode, metrics: metrics})
 	if err != nil {
-		log.Panicf("error create asset-transfer-basic chaincode: %s", err)
+		logFatal("server_start_failed", err)
 	}
 
-	server := &shim.ChaincodeServer{
-		CCID:    config.CCID,
-		Address: config.Address,
-		CC:      chaincode,
-		TLSProps: shim.TLSProperties{
-			Disabled: true,
-		},
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	serveErrors := make(chan error, 1)
+	go func() {
+		serveErrors <- server.Serve(status)
+	}()
+
+	logEvent("info", "server_started", map[string]interface{}{
+		"ccid":              config.CCID,
+		"address":           config.Address,
+		"tls":               !config.TLSProps.Disabled,
+		"mutualTLS":         config.TLSProps.ClientCACerts != nil,
+		"httpAddress":       os.Getenv("CHAINCODE_HTTP_ADDRESS"),
+		"keepaliveTime":     config.Keepalive.Time.String(),
+		"keepaliveTimeout":  config.Keepalive.Timeout.String(),
+		"keepaliveMinTime":  config.KeepaliveMinTime.String(),
+		"connectionTimeout": config.ConnectionTimeout.String(),
+		"shutdownTimeout":   config.ShutdownTimeout.String(),
+	})
+
+	select {
+	case err := <-serveErrors:
+		logFatal("server_failed", err)
+	case received := <-signals:
+		logEvent("info", "shutdown_started", map[string]interface{}{"signal": received.String()})
 	}
 
-	if err := server.Start(); err != nil {
-		log.Panicf("error starting asset-transfer-basic chaincode: %s", err)
+	status.setStopping()
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	running, err := server.Shutdown(ctx)
+	if err != nil {
+		logEvent("warn", "shutdown_timeout", map[string]interface{}{"abandonedInvocations": running})
+	}
+	if httpServer != nil {
+		httpServer.Shutdown(ctx)
 	}
+	logEvent("info", "server_stopped", nil)
 }
@@ -5,4 +5,12 @@ go 1.14
 require (
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200424173110-d7076418f212
 	github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200424173316-dd554ba3746e
+	github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-external/serverconfig v0.0.0
+	github.com/prometheus/client_golang v1.1.0
+	github.com/prometheus/client_model v0.0.0-20190812154241-14fe0d1b01d4 // indirect
+	google.golang.org/grpc v1.23.0
 )
+
+// The server configuration is a separate module, shared with chaincode/fabcar/external
+replace github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-external/serverconfig => ./serverconfig
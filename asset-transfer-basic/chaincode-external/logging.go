@@ -0,0 +1,44 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+)
+
+// eventLogger writes the structured startup and shutdown events of the chaincode server,
+// one JSON object per line
+var eventLogger = log.New(os.Stderr, "", 0)
+
+// logEvent logs an event with its level and fields. Error values are logged as their message.
+func logEvent(level string, event string, fields map[string]interface{}) {
+	entry := map[string]interface{}{
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+		"level": level,
+		"event": event,
+	}
+	for name, value := range fields {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		entry[name] = value
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		eventLogger.Printf("failed to log event %s: %v", event, err)
+		return
+	}
+	eventLogger.Println(string(line))
+}
+
+// logFatal logs an error event and exits
+func logFatal(event string, err error) {
+	logEvent("error", event, map[string]interface{}{"error": err})
+	os.Exit(1)
+}
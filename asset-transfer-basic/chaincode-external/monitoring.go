@@ -0,0 +1,176 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unknownFunction is the function label of the invocations of functions the contract does not have
+const unknownFunction = "unknown"
+
+// chaincodeMetrics are the Prometheus metrics of the chaincode invocations
+type chaincodeMetrics struct {
+	registry    *prometheus.Registry
+	invocations *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	latency     *prometheus.HistogramVec
+	// functions are the function names used as label, any other name is counted as unknown
+	// so that clients cannot create new time series by invoking arbitrary names
+	functions map[string]bool
+}
+
+func newChaincodeMetrics(contract contractapi.ContractInterface) *chaincodeMetrics {
+	metrics := &chaincodeMetrics{
+		functions: contractFunctions(contract),
+		registry:  prometheus.NewRegistry(),
+		invocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chaincode_invocations_total",
+			Help: "Number of chaincode invocations, by contract function.",
+		}, []string{"function"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chaincode_errors_total",
+			Help: "Number of chaincode invocations that returned an error, by contract function.",
+		}, []string{"function"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chaincode_invocation_duration_seconds",
+			Help:    "Duration of chaincode invocations, by contract function and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"function", "outcome"}),
+	}
+	metrics.registry.MustRegister(metrics.invocations, metrics.errors, metrics.latency)
+
+	return metrics
+}
+
+// metricsTransactionContext is the transaction context of the contract.
+// It records when the transaction started, for the after transaction hook.
+type metricsTransactionContext struct {
+	contractapi.TransactionContext
+	started time.Time
+}
+
+// beforeTransaction is the contractapi before transaction hook, it counts the invocation
+func (m *chaincodeMetrics) beforeTransaction(ctx *metricsTransactionContext) error {
+	ctx.started = time.Now()
+	m.invocations.WithLabelValues(m.functionLabel(ctx.GetStub())).Inc()
+	return nil
+}
+
+// afterTransaction is the contractapi after transaction hook. The contract API only calls it
+// when the transaction succeeds, errors are observed by instrumentedChaincode.
+func (m *chaincodeMetrics) afterTransaction(ctx *metricsTransactionContext, _ interface{}) error {
+	m.latency.WithLabelValues(m.functionLabel(ctx.GetStub()), "success").Observe(time.Since(ctx.started).Seconds())
+	return nil
+}
+
+// instrumentedChaincode counts the invocations of a chaincode that return an error
+type instrumentedChaincode struct {
+	shim.Chaincode
+	metrics *chaincodeMetrics
+}
+
+// Invoke calls the chaincode, and records the errors it returns
+func (c *instrumentedChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	started := time.Now()
+	response := c.Chaincode.Invoke(stub)
+	if response.Status >= shim.ERRORTHRESHOLD {
+		function := c.metrics.functionLabel(stub)
+		c.metrics.errors.WithLabelValues(function).Inc()
+		c.metrics.latency.WithLabelValues(function, "error").Observe(time.Since(started).Seconds())
+	}
+
+	return response
+}
+
+// functionLabel returns the name of the invoked contract function, without the contract name,
+// or unknown if the contract has no such function
+func (m *chaincodeMetrics) functionLabel(stub shim.ChaincodeStubInterface) string {
+	function, _ := stub.GetFunctionAndParameters()
+	function = function[strings.LastIndex(function, ":")+1:]
+	if !m.functions[function] {
+		return unknownFunction
+	}
+	return function
+}
+
+// contractFunctions returns the names of the transaction functions of a contract, which are its
+// exported methods other than those of contractapi.Contract
+func contractFunctions(contract contractapi.ContractInterface) map[string]bool {
+	ignored := map[string]bool{}
+	baseType := reflect.TypeOf(&contractapi.Contract{})
+	for i := 0; i < baseType.NumMethod(); i++ {
+		ignored[baseType.Method(i).Name] = true
+	}
+
+	functions := map[string]bool{}
+	contractType := reflect.TypeOf(contract)
+	for i := 0; i < contractType.NumMethod(); i++ {
+		if name := contractType.Method(i).Name; !ignored[name] {
+			functions[name] = true
+		}
+	}
+
+	return functions
+}
+
+// serverStatus tracks whether the chaincode gRPC server is serving
+type serverStatus struct {
+	state int32
+}
+
+const (
+	serverStarting int32 = iota
+	serverServing
+	serverStopping
+	serverStopped
+)
+
+func (s *serverStatus) setServing() {
+	atomic.StoreInt32(&s.state, serverServing)
+}
+
+func (s *serverStatus) setStopping() {
+	atomic.StoreInt32(&s.state, serverStopping)
+}
+
+func (s *serverStatus) setStopped() {
+	atomic.StoreInt32(&s.state, serverStopped)
+}
+
+func (s *serverStatus) isServing() bool {
+	return atomic.LoadInt32(&s.state) == serverServing
+}
+
+// newMonitoringHandler returns the handler of the HTTP side-listener:
+// /healthz reports that the process is alive, /readyz that the gRPC server has started and
+// is not shutting down,
+// and /metrics serves the chaincode metrics in the Prometheus format.
+func newMonitoringHandler(metrics *chaincodeMetrics, status *serverStatus) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !status.isServing() {
+			http.Error(w, "chaincode server not serving", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}))
+
+	return mux
+}
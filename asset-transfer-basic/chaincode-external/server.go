@@ -0,0 +1,180 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-external/serverconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// maxMessageSize is the maximum gRPC message size of shim.ChaincodeServer, which matches the peer
+const maxMessageSize = 100 * 1024 * 1024 // 100 MiB
+
+// chaincodeServer serves a chaincode to the peer like shim.ChaincodeServer does, but exposes
+// the listener and the gRPC server so that the process knows when it is ready to serve, and
+// can drain the in-flight invocations before stopping
+type chaincodeServer struct {
+	listener    net.Listener
+	server      *grpc.Server
+	invocations *invocationTracker
+}
+
+// newChaincodeServer listens on the configured address and registers the chaincode with
+// a new gRPC server. The chaincode is only served once Serve is called.
+func newChaincodeServer(config *serverconfig.Config, cc shim.Chaincode) (*chaincodeServer, error) {
+	serverOptions := []grpc.ServerOption{
+		grpc.KeepaliveParams(config.Keepalive),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             config.KeepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
+		grpc.MaxSendMsgSize(maxMessageSize),
+		grpc.MaxRecvMsgSize(maxMessageSize),
+		grpc.ConnectionTimeout(config.ConnectionTimeout),
+	}
+
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		serverOptions = append(serverOptions, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	invocations := newInvocationTracker()
+	server := grpc.NewServer(serverOptions...)
+	// shim.ChaincodeServer implements the peer.ChaincodeServer gRPC service
+	peer.RegisterChaincodeServer(server, &shim.ChaincodeServer{
+		CCID:    config.CCID,
+		Address: config.Address,
+		CC:      &drainingChaincode{Chaincode: cc, invocations: invocations},
+	})
+
+	return &chaincodeServer{listener: listener, server: server, invocations: invocations}, nil
+}
+
+// Serve serves the chaincode until the server is stopped. The status is set to serving once the
+// server accepts connections, and to stopped when Serve returns, whether it failed or was stopped.
+func (s *chaincodeServer) Serve(status *serverStatus) error {
+	defer status.setStopped()
+	return s.server.Serve(&acceptNotifyingListener{Listener: s.listener, accepting: status.setServing})
+}
+
+// acceptNotifyingListener calls accepting the first time the gRPC server accepts a connection,
+// that is once the server is serving
+type acceptNotifyingListener struct {
+	net.Listener
+	once      sync.Once
+	accepting func()
+}
+
+func (l *acceptNotifyingListener) Accept() (net.Conn, error) {
+	l.once.Do(l.accepting)
+	return l.Listener.Accept()
+}
+
+// Shutdown rejects new invocations, waits for the in-flight ones to complete, and stops the
+// server. The peer connection is a stream that stays open, so it is closed once the invocations
+// are drained rather than waited for. Shutdown returns the context error, and the number of
+// invocations still running, when the context is done before the invocations are drained.
+func (s *chaincodeServer) Shutdown(ctx context.Context) (int, error) {
+	defer s.server.Stop()
+
+	select {
+	case <-s.invocations.drain():
+		return 0, nil
+	case <-ctx.Done():
+		return s.invocations.running(), ctx.Err()
+	}
+}
+
+// invocationTracker counts the in-flight invocations of a chaincode
+type invocationTracker struct {
+	mutex    sync.Mutex
+	inFlight int
+	draining bool
+	drained  chan struct{}
+}
+
+func newInvocationTracker() *invocationTracker {
+	return &invocationTracker{drained: make(chan struct{})}
+}
+
+// begin records the start of an invocation. It returns false once the tracker is draining,
+// in which case the invocation must be rejected.
+func (t *invocationTracker) begin() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.draining {
+		return false
+	}
+	t.inFlight++
+	return true
+}
+
+// end records the end of an invocation
+func (t *invocationTracker) end() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.inFlight--
+	if t.draining && t.inFlight == 0 {
+		close(t.drained)
+	}
+}
+
+// drain stops accepting invocations, and returns a channel closed once the in-flight
+// invocations have completed
+func (t *invocationTracker) drain() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.draining {
+		t.draining = true
+		if t.inFlight == 0 {
+			close(t.drained)
+		}
+	}
+	return t.drained
+}
+
+// running returns the number of in-flight invocations
+func (t *invocationTracker) running() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.inFlight
+}
+
+// drainingChaincode tracks the invocations of a chaincode, and rejects them once the
+// server is shutting down
+type drainingChaincode struct {
+	shim.Chaincode
+	invocations *invocationTracker
+}
+
+// Invoke calls the chaincode unless the server is shutting down
+func (c *drainingChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	if !c.invocations.begin() {
+		return shim.Error("the chaincode server is shutting down")
+	}
+	defer c.invocations.end()
+
+	return c.Chaincode.Invoke(stub)
+}
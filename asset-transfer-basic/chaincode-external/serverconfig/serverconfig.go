@@ -0,0 +1,233 @@
+/*
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package serverconfig loads the configuration of an external chaincode server from
+// environment variables. It is a module of its own, shared by the external chaincode samples, see the
+// chaincode.env file of asset-transfer-basic/chaincode-external for the variables.
+package serverconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Environment variables read by FromEnv
+const (
+	CCIDEnv         = "CHAINCODE_ID"
+	AddressEnv      = "CHAINCODE_SERVER_ADDRESS"
+	TLSDisabledEnv  = "CHAINCODE_TLS_DISABLED"
+	TLSKeyEnv       = "CHAINCODE_TLS_KEY"
+	TLSCertEnv      = "CHAINCODE_TLS_CERT"
+	ClientCACertEnv = "CHAINCODE_CLIENT_CA_CERT"
+	ClientAuthEnv   = "CHAINCODE_TLS_CLIENT_AUTH"
+
+	KeepaliveTimeEnv     = "CHAINCODE_KEEPALIVE_TIME"
+	KeepaliveTimeoutEnv  = "CHAINCODE_KEEPALIVE_TIMEOUT"
+	KeepaliveMinTimeEnv  = "CHAINCODE_KEEPALIVE_MIN_TIME"
+	ConnectionTimeoutEnv = "CHAINCODE_CONNECTION_TIMEOUT"
+	ShutdownTimeoutEnv   = "CHAINCODE_SHUTDOWN_TIMEOUT"
+)
+
+// Default durations, the keepalive and connection timeout defaults are those of shim.ChaincodeServer
+const (
+	DefaultKeepaliveTime     = 1 * time.Minute
+	DefaultKeepaliveTimeout  = 20 * time.Second
+	DefaultKeepaliveMinTime  = 1 * time.Minute
+	DefaultConnectionTimeout = 5 * time.Second
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// Config is the configuration of an external chaincode server
+type Config struct {
+	CCID     string
+	Address  string
+	TLSProps shim.TLSProperties
+	// Keepalive is how often the server pings idle peer connections, and how long it waits
+	// for the ping to be acknowledged before closing the connection
+	Keepalive keepalive.ServerParameters
+	// KeepaliveMinTime is the minimum time between the pings of a peer. Peers pinging more
+	// often are disconnected.
+	KeepaliveMinTime time.Duration
+	// ConnectionTimeout is the time allowed to establish a new connection, TLS handshake included
+	ConnectionTimeout time.Duration
+	// ShutdownTimeout is the time allowed to in-flight invocations to complete when the
+	// server is stopped
+	ShutdownTimeout time.Duration
+}
+
+// FromEnv reads the server configuration from the environment.
+//
+// TLS is disabled unless CHAINCODE_TLS_DISABLED is set to false, in which case
+// CHAINCODE_TLS_KEY and CHAINCODE_TLS_CERT must be the paths of the PEM encoded key and
+// certificate of the server. Setting CHAINCODE_TLS_CLIENT_AUTH to true enables mutual TLS,
+// and requires CHAINCODE_CLIENT_CA_CERT to be the path of the PEM encoded root certificates
+// used to verify the peer. The files are read and checked before the server starts, so that
+// a misconfiguration is reported at startup rather than on the first peer connection.
+//
+// The keepalive, connection and shutdown durations are read from CHAINCODE_KEEPALIVE_TIME,
+// CHAINCODE_KEEPALIVE_TIMEOUT, CHAINCODE_KEEPALIVE_MIN_TIME, CHAINCODE_CONNECTION_TIMEOUT and
+// CHAINCODE_SHUTDOWN_TIMEOUT, formatted as Go durations (e.g. 30s or 2m).
+func FromEnv() (*Config, error) {
+	config := &Config{
+		CCID:    os.Getenv(CCIDEnv),
+		Address: os.Getenv(AddressEnv),
+	}
+	if config.CCID == "" {
+		return nil, fmt.Errorf("%s must be set to the package ID of the chaincode", CCIDEnv)
+	}
+	if config.Address == "" {
+		return nil, fmt.Errorf("%s must be set to the listen address of the chaincode server", AddressEnv)
+	}
+
+	durations := []struct {
+		name         string
+		value        *time.Duration
+		defaultValue time.Duration
+	}{
+		{KeepaliveTimeEnv, &config.Keepalive.Time, DefaultKeepaliveTime},
+		{KeepaliveTimeoutEnv, &config.Keepalive.Timeout, DefaultKeepaliveTimeout},
+		{KeepaliveMinTimeEnv, &config.KeepaliveMinTime, DefaultKeepaliveMinTime},
+		{ConnectionTimeoutEnv, &config.ConnectionTimeout, DefaultConnectionTimeout},
+		{ShutdownTimeoutEnv, &config.ShutdownTimeout, DefaultShutdownTimeout},
+	}
+	for _, duration := range durations {
+		value, err := durationFromEnv(duration.name, duration.defaultValue)
+		if err != nil {
+			return nil, err
+		}
+		*duration.value = value
+	}
+
+	tlsDisabled, err := boolFromEnv(TLSDisabledEnv, true)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth, err := boolFromEnv(ClientAuthEnv, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsDisabled {
+		if clientAuth {
+			return nil, fmt.Errorf("%s requires TLS, set %s to false", ClientAuthEnv, TLSDisabledEnv)
+		}
+		config.TLSProps.Disabled = true
+		return config, nil
+	}
+
+	config.TLSProps.Key, err = readFileFromEnv(TLSKeyEnv, "TLS key")
+	if err != nil {
+		return nil, err
+	}
+	config.TLSProps.Cert, err = readFileFromEnv(TLSCertEnv, "TLS certificate")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tls.X509KeyPair(config.TLSProps.Cert, config.TLSProps.Key); err != nil {
+		return nil, fmt.Errorf("invalid TLS key pair %s and %s: %v", os.Getenv(TLSCertEnv), os.Getenv(TLSKeyEnv), err)
+	}
+
+	if clientAuth {
+		config.TLSProps.ClientCACerts, err = readFileFromEnv(ClientCACertEnv, "client CA certificate")
+		if err != nil {
+			return nil, err
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(config.TLSProps.ClientCACerts) {
+			return nil, fmt.Errorf("no PEM encoded certificate found in the client CA certificate file %s", os.Getenv(ClientCACertEnv))
+		}
+	}
+
+	return config, nil
+}
+
+// boolFromEnv parses a boolean environment variable, returning defaultValue when it is not set
+func boolFromEnv(name string, defaultValue bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q for %s, expected true or false", value, name)
+	}
+
+	return parsed, nil
+}
+
+// durationFromEnv parses a positive duration environment variable, returning defaultValue
+// when it is not set
+func durationFromEnv(name string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("invalid value %q for %s, expected a positive duration such as 30s", value, name)
+	}
+
+	return parsed, nil
+}
+
+// readFileFromEnv reads the file whose path is set in an environment variable
+func readFileFromEnv(name string, description string) ([]byte, error) {
+	path := os.Getenv(name)
+	if path == "" {
+		return nil, fmt.Errorf("%s must be set to the path of the %s file when TLS is enabled", name, description)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the %s file set in %s: %v", description, name, err)
+	}
+
+	return contents, nil
+}
+
+// TLSConfig returns the TLS configuration of the chaincode server, or nil when TLS is disabled.
+// It follows the defaults of the shim chaincode server, which match the peer server defaults.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if c.TLSProps.Disabled {
+		return nil, nil
+	}
+
+	certificate, err := tls.X509KeyPair(c.TLSProps.Cert, c.TLSProps.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the TLS key pair: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:             tls.VersionTLS12,
+		Certificates:           []tls.Certificate{certificate},
+		SessionTicketsDisabled: true,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+		},
+	}
+
+	if c.TLSProps.ClientCACerts != nil {
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(c.TLSProps.ClientCACerts) {
+			return nil, fmt.Errorf("failed to parse the client CA certificates")
+		}
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,213 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// OwnershipMode selects who is allowed to change an asset
+type OwnershipMode string
+
+const (
+	// PermissiveOwnership lets any client update, transfer or delete any asset. Owner is a free-form name.
+	PermissiveOwnership OwnershipMode = "permissive"
+	// IdentityOwnership sets Owner to the client identity of the creator of an asset. Only the owner,
+	// or a client of the admin MSP, can then update, transfer or delete the asset.
+	IdentityOwnership OwnershipMode = "identity"
+)
+
+// ownershipConfigObjectType is the object type of the composite key of the ownership config.
+// Composite keys are not returned by range queries, so the config is not listed as an asset.
+const ownershipConfigObjectType = "ownershipConfig"
+
+// OwnershipConfig is the ownership mode of the channel. It is kept in the world state rather than
+// in the environment of each peer, so that all endorsing peers enforce the same mode.
+type OwnershipConfig struct {
+	// Mode is the ownership mode, PermissiveOwnership if empty
+	Mode OwnershipMode `json:"mode"`
+	// AdminMSPID is the MSP whose clients can change any asset in IdentityOwnership mode, and
+	// change the ownership config
+	AdminMSPID string `json:"adminMSPID"`
+}
+
+// initOwnershipFunction is the function of the initialization transaction that sets the
+// initial ownership config, see Chaincode.Init
+const initOwnershipFunction = "InitOwnership"
+
+// Chaincode is the asset transfer chaincode. It runs the SmartContract, and sets the initial
+// ownership config when the chaincode is initialized.
+type Chaincode struct {
+	*contractapi.ContractChaincode
+}
+
+// NewChaincode returns the asset transfer chaincode
+func NewChaincode() (*Chaincode, error) {
+	contractChaincode, err := contractapi.NewChaincode(&SmartContract{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chaincode{ContractChaincode: contractChaincode}, nil
+}
+
+// Start starts the chaincode, with the Init of Chaincode rather than the one of the contract
+func (cc *Chaincode) Start() error {
+	return shim.Start(cc)
+}
+
+// Init is only called by the peer for the initialization transaction of a chaincode definition
+// committed with --init-required, which is the first transaction of the chaincode and is only
+// accepted once. Called with InitOwnership, the ownership mode and the admin MSP, as in
+// peer chaincode invoke --isInit -c '{"function":"InitOwnership","Args":["identity","Org1MSP"]}',
+// it sets the initial ownership config, which only the admin MSP can change afterwards. Other
+// functions, such as InitLedger, are run by the contract. Until it is initialized with
+// InitOwnership, the chaincode stays in PermissiveOwnership mode.
+func (cc *Chaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	function, params := stub.GetFunctionAndParameters()
+	if function != initOwnershipFunction {
+		return cc.ContractChaincode.Init(stub)
+	}
+	if len(params) != 2 {
+		return shim.Error(fmt.Sprintf("%s takes the ownership mode and the admin MSP ID", initOwnershipFunction))
+	}
+
+	current, err := getOwnershipConfig(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if current != nil {
+		return shim.Error("the ownership config is already set, change it with SetOwnershipConfig")
+	}
+
+	err = putOwnershipConfig(stub, params[0], params[1])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// SetOwnershipConfig changes the ownership mode, permissive or identity, and the admin MSP. Only a
+// client of the current admin MSP can change it, once the chaincode was initialized with InitOwnership.
+func (s *SmartContract) SetOwnershipConfig(ctx contractapi.TransactionContextInterface, mode string, adminMSPID string) error {
+	current, err := getOwnershipConfig(ctx.GetStub())
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("the ownership config is set when the chaincode is initialized with %s", initOwnershipFunction)
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if clientMSPID != current.AdminMSPID {
+		return fmt.Errorf("only clients of the admin MSP %s can change the ownership config", current.AdminMSPID)
+	}
+
+	return putOwnershipConfig(ctx.GetStub(), mode, adminMSPID)
+}
+
+// GetOwnershipConfig returns the ownership config in effect
+func (s *SmartContract) GetOwnershipConfig(ctx contractapi.TransactionContextInterface) (*OwnershipConfig, error) {
+	return readOwnershipConfig(ctx)
+}
+
+// readOwnershipConfig returns the ownership config in effect, which is the PermissiveOwnership
+// config if none was set
+func readOwnershipConfig(ctx contractapi.TransactionContextInterface) (*OwnershipConfig, error) {
+	config, err := getOwnershipConfig(ctx.GetStub())
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return &OwnershipConfig{Mode: PermissiveOwnership}, nil
+	}
+
+	return config, nil
+}
+
+// getOwnershipConfig returns the ownership config set in the world state, or nil if none was set
+func getOwnershipConfig(stub shim.ChaincodeStubInterface) (*OwnershipConfig, error) {
+	key, err := ownershipConfigKey()
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if configJSON == nil {
+		return nil, nil
+	}
+
+	var config OwnershipConfig
+	err = json.Unmarshal(configJSON, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// putOwnershipConfig validates and saves an ownership config. The admin MSP is required, so that
+// the config can always be changed.
+func putOwnershipConfig(stub shim.ChaincodeStubInterface, mode string, adminMSPID string) error {
+	config := OwnershipConfig{Mode: OwnershipMode(mode), AdminMSPID: adminMSPID}
+	if config.Mode != PermissiveOwnership && config.Mode != IdentityOwnership {
+		return fmt.Errorf("invalid ownership mode %q, use %s or %s", mode, PermissiveOwnership, IdentityOwnership)
+	}
+	if config.AdminMSPID == "" {
+		return fmt.Errorf("the admin MSP ID of the ownership config must not be empty")
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	key, err := ownershipConfigKey()
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(key, configJSON)
+}
+
+func ownershipConfigKey() (string, error) {
+	return shim.CreateCompositeKey(ownershipConfigObjectType, []string{})
+}
+
+// authorizeOwner checks that the submitting client may change the asset. In IdentityOwnership mode,
+// this is the owner of the asset or a client of the admin MSP. In PermissiveOwnership mode, any client may.
+func (s *SmartContract) authorizeOwner(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	config, err := readOwnershipConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.Mode != IdentityOwnership {
+		return nil
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client identity: %v", err)
+	}
+	if clientID == asset.Owner {
+		return nil
+	}
+
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+	if clientMSPID == config.AdminMSPID {
+		return nil
+	}
+
+	return fmt.Errorf("the client is not authorized to change the asset %s, only its owner can", asset.ID)
+}
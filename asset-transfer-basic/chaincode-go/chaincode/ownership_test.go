@@ -0,0 +1,113 @@
+package chaincode_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+// stubWorldState backs the state functions of the stub with a map, which it returns
+func stubWorldState(chaincodeStub *mocks.ChaincodeStub) map[string][]byte {
+	worldState := map[string][]byte{}
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		return worldState[key], nil
+	}
+	chaincodeStub.PutStateStub = func(key string, value []byte) error {
+		worldState[key] = value
+		return nil
+	}
+	chaincodeStub.DelStateStub = func(key string) error {
+		delete(worldState, key)
+		return nil
+	}
+	return worldState
+}
+
+// initOwnership initializes the chaincode with InitOwnership, the mode and the admin MSP
+func initOwnership(t *testing.T, chaincodeStub *mocks.ChaincodeStub, mode string, adminMSPID string) {
+	assetChaincode, err := chaincode.NewChaincode()
+	require.NoError(t, err)
+	chaincodeStub.GetFunctionAndParametersReturns("InitOwnership", []string{mode, adminMSPID})
+	response := assetChaincode.Init(chaincodeStub)
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+}
+
+func TestInitOwnership(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	worldState := stubWorldState(chaincodeStub)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetChaincode, err := chaincode.NewChaincode()
+	require.NoError(t, err)
+	init := func(function string, params ...string) peer.Response {
+		chaincodeStub.GetFunctionAndParametersReturns(function, params)
+		return assetChaincode.Init(chaincodeStub)
+	}
+
+	// Other functions are run by the contract
+	response := init("")
+	require.Equal(t, int32(shim.OK), response.Status)
+
+	response = init("InitOwnership", "identity")
+	require.Equal(t, "InitOwnership takes the ownership mode and the admin MSP ID", response.Message)
+	response = init("InitOwnership", "owner", "AdminMSP")
+	require.Equal(t, `invalid ownership mode "owner", use permissive or identity`, response.Message)
+	response = init("InitOwnership", "identity", "")
+	require.Equal(t, "the admin MSP ID of the ownership config must not be empty", response.Message)
+	require.Empty(t, worldState)
+
+	response = init("InitOwnership", "identity", "AdminMSP")
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+	config, err := (&chaincode.SmartContract{}).GetOwnershipConfig(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.OwnershipConfig{Mode: chaincode.IdentityOwnership, AdminMSPID: "AdminMSP"}, config)
+
+	// The config is kept under a composite key, out of the range of the asset keys
+	for key := range worldState {
+		require.True(t, strings.HasPrefix(key, "\x00"), "unexpected key %q", key)
+	}
+
+	response = init("InitOwnership", "permissive", "Org1MSP")
+	require.Equal(t, "the ownership config is already set, change it with SetOwnershipConfig", response.Message)
+}
+
+func TestSetOwnershipConfig(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	stubWorldState(chaincodeStub)
+	clientIdentity := &mocks.ClientIdentity{}
+	clientIdentity.GetMSPIDReturns("Org1MSP", nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext.GetClientIdentityReturns(clientIdentity)
+
+	assetTransfer := chaincode.SmartContract{}
+	config, err := assetTransfer.GetOwnershipConfig(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.OwnershipConfig{Mode: chaincode.PermissiveOwnership}, config)
+
+	// The first config is set by the initialization transaction, not by the first client
+	err = assetTransfer.SetOwnershipConfig(transactionContext, "identity", "Org1MSP")
+	require.EqualError(t, err, "the ownership config is set when the chaincode is initialized with InitOwnership")
+
+	initOwnership(t, chaincodeStub, "identity", "AdminMSP")
+	err = assetTransfer.SetOwnershipConfig(transactionContext, "permissive", "Org1MSP")
+	require.EqualError(t, err, "only clients of the admin MSP AdminMSP can change the ownership config")
+
+	clientIdentity.GetMSPIDReturns("AdminMSP", nil)
+	err = assetTransfer.SetOwnershipConfig(transactionContext, "owner", "AdminMSP")
+	require.EqualError(t, err, `invalid ownership mode "owner", use permissive or identity`)
+	err = assetTransfer.SetOwnershipConfig(transactionContext, "permissive", "")
+	require.EqualError(t, err, "the admin MSP ID of the ownership config must not be empty")
+
+	err = assetTransfer.SetOwnershipConfig(transactionContext, "permissive", "Org1MSP")
+	require.NoError(t, err)
+	config, err = assetTransfer.GetOwnershipConfig(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.OwnershipConfig{Mode: chaincode.PermissiveOwnership, AdminMSPID: "Org1MSP"}, config)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -19,17 +20,66 @@ type Asset struct {
 	Size           int    `json:"size"`
 	Owner          string `json:"owner"`
 	AppraisedValue int    `json:"appraisedValue"`
+	// Version is incremented on every write, starting at 1 when the asset is created
+	Version int `json:"version"`
+	// Attributes are optional free-form metadata, set with SetAttribute
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Tags are optional labels, indexed so that assets can be queried by tag
+	Tags []string `json:"tags,omitempty"`
+}
+
+// assetEventVersion is the version of the AssetEvent payload format. It is incremented
+// when a change of the format could break event listeners.
+const assetEventVersion = 1
+
+// Names of the chaincode events emitted on asset changes
+const (
+	AssetCreatedEvent     = "AssetCreated"
+	AssetUpdatedEvent     = "AssetUpdated"
+	AssetTransferredEvent = "AssetTransferred"
+	AssetDeletedEvent     = "AssetDeleted"
+)
+
+// AssetEvent is the payload of the chaincode event emitted on each asset change.
+// Before is empty for AssetCreated events and After is empty for AssetDeleted events.
+type AssetEvent struct {
+	Version int    `json:"version"`
+	Type    string `json:"type"`
+	ID      string `json:"ID"`
+	TxID    string `json:"txID"`
+	Before  *Asset `json:"before,omitempty"`
+	After   *Asset `json:"after,omitempty"`
+}
+
+// PaginatedQueryResult structure used for returning paginated query results and metadata
+type PaginatedQueryResult struct {
+	Records             []*Asset `json:"records"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
+
+// VersionConflictError is returned when an asset is written with an expected version
+// that does not match the version in the world state, e.g. because another client
+// updated the asset in the meantime.
+type VersionConflictError struct {
+	ID              string
+	ExpectedVersion int
+	ActualVersion   int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict on asset %s: expected version %d, found version %d", e.ID, e.ExpectedVersion, e.ActualVersion)
 }
 
 // InitLedger adds a base set of assets to the ledger
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
 	assets := []Asset{
-		{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300},
-		{ID: "asset2", Color: "red", Size: 5, Owner: "Brad", AppraisedValue: 400},
-		{ID: "asset3", Color: "green", Size: 10, Owner: "Jin Soo", AppraisedValue: 500},
-		{ID: "asset4", Color: "yellow", Size: 10, Owner: "Max", AppraisedValue: 600},
-		{ID: "asset5", Color: "black", Size: 15, Owner: "Adriana", AppraisedValue: 700},
-		{ID: "asset6", Color: "white", Size: 15, Owner: "Michel", AppraisedValue: 800},
+		{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, Version: 1},
+		{ID: "asset2", Color: "red", Size: 5, Owner: "Brad", AppraisedValue: 400, Version: 1},
+		{ID: "asset3", Color: "green", Size: 10, Owner: "Jin Soo", AppraisedValue: 500, Version: 1},
+		{ID: "asset4", Color: "yellow", Size: 10, Owner: "Max", AppraisedValue: 600, Version: 1},
+		{ID: "asset5", Color: "black", Size: 15, Owner: "Adriana", AppraisedValue: 700, Version: 1},
+		{ID: "asset6", Color: "white", Size: 15, Owner: "Michel", AppraisedValue: 800, Version: 1},
 	}
 
 	for _, asset := range assets {
@@ -48,6 +98,7 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 }
 
 // CreateAsset issues a new asset to the world state with given details.
+// In IdentityOwnership mode, the owner is the submitting client and the owner argument is ignored.
 func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface, id string, color string, size int, owner string, appraisedValue int) error {
 	exists, err := s.AssetExists(ctx, id)
 	if err != nil {
@@ -57,19 +108,41 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("the asset %s already exists", id)
 	}
 
+	config, err := readOwnershipConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if config.Mode == IdentityOwnership {
+		owner, err = ctx.GetClientIdentity().GetID()
+		if err != nil {
+			return fmt.Errorf("failed to get client identity: %v", err)
+		}
+	}
+
 	asset := Asset{
 		ID:             id,
 		Color:          color,
 		Size:           size,
 		Owner:          owner,
 		AppraisedValue: appraisedValue,
+		Version:        1,
+	}
+	err = validateAsset(&asset)
+	if err != nil {
+		return err
 	}
+
 	assetJSON, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	err = ctx.GetStub().PutState(id, assetJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetCreatedEvent, nil, &asset)
 }
 
 // ReadAsset returns the asset stored in the world state with given id.
@@ -93,41 +166,110 @@ func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, i
 
 // UpdateAsset updates an existing asset in the world state with provided parameters.
 func (s *SmartContract) UpdateAsset(ctx contractapi.TransactionContextInterface, id string, color string, size int, owner string, appraisedValue int) error {
-	exists, err := s.AssetExists(ctx, id)
+	asset, err := s.readAuthorizedAsset(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+
+	return s.updateAsset(ctx, asset, color, size, owner, appraisedValue)
+}
+
+// UpdateAssetIfVersion updates an existing asset like UpdateAsset, only if the asset in the
+// world state still has the expected version. Otherwise a VersionConflictError is returned.
+func (s *SmartContract) UpdateAssetIfVersion(ctx contractapi.TransactionContextInterface, id string, expectedVersion int, color string, size int, owner string, appraisedValue int) error {
+	asset, err := s.readAssetIfVersion(ctx, id, expectedVersion)
+	if err != nil {
+		return err
+	}
+	err = s.authorizeOwner(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return s.updateAsset(ctx, asset, color, size, owner, appraisedValue)
+}
+
+// updateAsset overwrites the original asset with the provided parameters and the next version
+// The attributes and tags of the original asset are kept. In IdentityOwnership mode, the owner
+// can only be changed with TransferAsset.
+func (s *SmartContract) updateAsset(ctx contractapi.TransactionContextInterface, original *Asset, color string, size int, owner string, appraisedValue int) error {
+	if owner != original.Owner {
+		config, err := readOwnershipConfig(ctx)
+		if err != nil {
+			return err
+		}
+		if config.Mode == IdentityOwnership {
+			return fmt.Errorf("the owner of asset %s cannot be updated in %s ownership mode, use TransferAsset", original.ID, IdentityOwnership)
+		}
 	}
 
-	// overwriting original asset with new asset
 	asset := Asset{
-		ID:             id,
+		ID:             original.ID,
 		Color:          color,
 		Size:           size,
 		Owner:          owner,
 		AppraisedValue: appraisedValue,
+		Attributes:     original.Attributes,
+		Tags:           original.Tags,
 	}
+
+	return putUpdatedAsset(ctx, original, &asset)
+}
+
+// putUpdatedAsset validates and saves a new state of the original asset with the next version
+func putUpdatedAsset(ctx contractapi.TransactionContextInterface, original *Asset, asset *Asset) error {
+	asset.Version = original.Version + 1
+	err := validateAsset(asset)
+	if err != nil {
+		return err
+	}
+
 	assetJSON, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	err = ctx.GetStub().PutState(asset.ID, assetJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetUpdatedEvent, original, asset)
+}
+
+// readAssetIfVersion returns the asset with given id if it has the expected version
+func (s *SmartContract) readAssetIfVersion(ctx contractapi.TransactionContextInterface, id string, expectedVersion int) (*Asset, error) {
+	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if asset.Version != expectedVersion {
+		return nil, &VersionConflictError{ID: id, ExpectedVersion: expectedVersion, ActualVersion: asset.Version}
+	}
+
+	return asset, nil
 }
 
 // DeleteAsset deletes an given asset from the world state.
 func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface, id string) error {
-	exists, err := s.AssetExists(ctx, id)
+	asset, err := s.readAuthorizedAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(id)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the asset %s does not exist", id)
+
+	for _, tag := range asset.Tags {
+		err = deleteTagIndexEntry(ctx, tag, id)
+		if err != nil {
+			return err
+		}
 	}
 
-	return ctx.GetStub().DelState(id)
+	return emitAssetEvent(ctx, AssetDeletedEvent, asset, nil)
 }
 
 // AssetExists returns true when asset with given ID exists in world state
@@ -142,18 +284,88 @@ func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface,
 
 // TransferAsset updates the owner field of asset with given id in world state.
 func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface, id string, newOwner string) error {
+	asset, err := s.readAuthorizedAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.transferAsset(ctx, asset, newOwner)
+}
+
+// TransferAssetIfVersion updates the owner field of asset like TransferAsset, only if the asset in
+// the world state still has the expected version. Otherwise a VersionConflictError is returned.
+func (s *SmartContract) TransferAssetIfVersion(ctx contractapi.TransactionContextInterface, id string, expectedVersion int, newOwner string) error {
+	asset, err := s.readAssetIfVersion(ctx, id, expectedVersion)
+	if err != nil {
+		return err
+	}
+	err = s.authorizeOwner(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return s.transferAsset(ctx, asset, newOwner)
+}
+
+// readAuthorizedAsset returns the asset with given id if the submitting client may change it
+func (s *SmartContract) readAuthorizedAsset(ctx contractapi.TransactionContextInterface, id string) (*Asset, error) {
 	asset, err := s.ReadAsset(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	err = s.authorizeOwner(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return asset, nil
+}
+
+// transferAsset sets the new owner on the asset and saves it with the next version
+func (s *SmartContract) transferAsset(ctx contractapi.TransactionContextInterface, asset *Asset, newOwner string) error {
+	err := validateOwner(asset.ID, newOwner)
 	if err != nil {
 		return err
 	}
 
+	before := *asset
 	asset.Owner = newOwner
+	asset.Version++
 	assetJSON, err := json.Marshal(asset)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(id, assetJSON)
+	err = ctx.GetStub().PutState(asset.ID, assetJSON)
+	if err != nil {
+		return err
+	}
+
+	return emitAssetEvent(ctx, AssetTransferredEvent, &before, asset)
+}
+
+// emitAssetEvent sets the chaincode event of the transaction to the change of an asset.
+// A transaction has a single chaincode event, so each function emits at most one.
+func emitAssetEvent(ctx contractapi.TransactionContextInterface, eventType string, before *Asset, after *Asset) error {
+	event := AssetEvent{
+		Version: assetEventVersion,
+		Type:    eventType,
+		TxID:    ctx.GetStub().GetTxID(),
+		Before:  before,
+		After:   after,
+	}
+	if after != nil {
+		event.ID = after.ID
+	} else {
+		event.ID = before.ID
+	}
+
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().SetEvent(eventType, eventJSON)
 }
 
 // GetAllAssets returns all assets found in world state
@@ -166,6 +378,44 @@ func (s *SmartContract) GetAllAssets(ctx contractapi.TransactionContextInterface
 	}
 	defer resultsIterator.Close()
 
+	return constructQueryResponseFromIterator(resultsIterator)
+}
+
+// GetAssetsPage returns a page of all assets found in world state, starting at the bookmark.
+// Pass an empty bookmark for the first page, and the returned bookmark for the next ones.
+// Paginated queries are only valid for read only transactions.
+func (s *SmartContract) GetAssetsPage(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	return s.GetAssetsByRange(ctx, "", "", pageSize, bookmark)
+}
+
+// GetAssetsByRange returns a page of the assets with an ID from startKey (inclusive) to endKey
+// (exclusive), starting at the bookmark. Empty keys leave the range open ended.
+// Paginated queries are only valid for read only transactions.
+func (s *SmartContract) GetAssetsByRange(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be a positive integer")
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	assets, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             assets,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// constructQueryResponseFromIterator constructs a slice of assets from the resultsIterator
+func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorInterface) ([]*Asset, error) {
 	var assets []*Asset
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
@@ -2,12 +2,15 @@ package chaincode_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
 
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
 	"github.com/stretchr/testify/require"
@@ -28,6 +31,11 @@ type stateQueryIterator interface {
 	shim.StateQueryIteratorInterface
 }
 
+//go:generate counterfeiter -o mocks/clientidentity.go -fake-name ClientIdentity . clientIdentity
+type clientIdentity interface {
+	cid.ClientIdentity
+}
+
 func TestInitLedger(t *testing.T) {
 	chaincodeStub := &mocks.ChaincodeStub{}
 	transactionContext := &mocks.TransactionContext{}
@@ -48,7 +56,7 @@ func TestCreateAsset(t *testing.T) {
 	transactionContext.GetStubReturns(chaincodeStub)
 
 	assetTransfer := chaincode.SmartContract{}
-	err := assetTransfer.CreateAsset(transactionContext, "", "", 0, "", 0)
+	err := assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", 300)
 	require.NoError(t, err)
 
 	chaincodeStub.GetStateReturns([]byte{}, nil)
@@ -96,7 +104,7 @@ func TestUpdateAsset(t *testing.T) {
 
 	chaincodeStub.GetStateReturns(bytes, nil)
 	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.UpdateAsset(transactionContext, "", "", 0, "", 0)
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", 300)
 	require.NoError(t, err)
 
 	chaincodeStub.GetStateReturns(nil, nil)
@@ -108,6 +116,37 @@ func TestUpdateAsset(t *testing.T) {
 	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
 }
 
+func TestUpdateAssetIfVersion(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	asset := &chaincode.Asset{ID: "asset1", Version: 2}
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	chaincodeStub.GetStateReturns(bytes, nil)
+	assetTransfer := chaincode.SmartContract{}
+	err = assetTransfer.UpdateAssetIfVersion(transactionContext, "asset1", 2, "blue", 5, "Tomoko", 300)
+	require.NoError(t, err)
+
+	_, updatedBytes := chaincodeStub.PutStateArgsForCall(0)
+	var updatedAsset chaincode.Asset
+	require.NoError(t, json.Unmarshal(updatedBytes, &updatedAsset))
+	require.Equal(t, chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, Version: 3}, updatedAsset)
+
+	err = assetTransfer.UpdateAssetIfVersion(transactionContext, "asset1", 1, "blue", 5, "Tomoko", 300)
+	require.EqualError(t, err, "version conflict on asset asset1: expected version 1, found version 2")
+	var conflict *chaincode.VersionConflictError
+	require.True(t, errors.As(err, &conflict))
+	require.Equal(t, 2, conflict.ActualVersion)
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+
+	chaincodeStub.GetStateReturns(nil, nil)
+	err = assetTransfer.UpdateAssetIfVersion(transactionContext, "asset1", 2, "", 0, "", 0)
+	require.EqualError(t, err, "the asset asset1 does not exist")
+}
+
 func TestDeleteAsset(t *testing.T) {
 	chaincodeStub := &mocks.ChaincodeStub{}
 	transactionContext := &mocks.TransactionContext{}
@@ -143,14 +182,149 @@ func TestTransferAsset(t *testing.T) {
 
 	chaincodeStub.GetStateReturns(bytes, nil)
 	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.TransferAsset(transactionContext, "", "")
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "Brad")
 	require.NoError(t, err)
 
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "")
+	require.EqualError(t, err, "the asset asset1 is invalid: owner must not be empty")
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+
 	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
 	err = assetTransfer.TransferAsset(transactionContext, "", "")
 	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
 }
 
+func TestTransferAssetIfVersion(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	asset := &chaincode.Asset{ID: "asset1", Owner: "Tomoko", Version: 1}
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	chaincodeStub.GetStateReturns(bytes, nil)
+	assetTransfer := chaincode.SmartContract{}
+	err = assetTransfer.TransferAssetIfVersion(transactionContext, "asset1", 1, "Brad")
+	require.NoError(t, err)
+
+	_, transferredBytes := chaincodeStub.PutStateArgsForCall(0)
+	var transferredAsset chaincode.Asset
+	require.NoError(t, json.Unmarshal(transferredBytes, &transferredAsset))
+	require.Equal(t, "Brad", transferredAsset.Owner)
+	require.Equal(t, 2, transferredAsset.Version)
+
+	err = assetTransfer.TransferAssetIfVersion(transactionContext, "asset1", 3, "Brad")
+	require.EqualError(t, err, "version conflict on asset asset1: expected version 3, found version 1")
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+}
+
+func TestIdentityOwnership(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	worldState := stubWorldState(chaincodeStub)
+	clientIdentity := &mocks.ClientIdentity{}
+	clientIdentity.GetIDReturns("client1", nil)
+	clientIdentity.GetMSPIDReturns("Org1MSP", nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext.GetClientIdentityReturns(clientIdentity)
+
+	initOwnership(t, chaincodeStub, "identity", "AdminMSP")
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", 300)
+	require.NoError(t, err)
+
+	var createdAsset chaincode.Asset
+	require.NoError(t, json.Unmarshal(worldState["asset1"], &createdAsset))
+	require.Equal(t, "client1", createdAsset.Owner)
+
+	// The owner can update the asset, but only change its owner with TransferAsset
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 5, "client1", 300)
+	require.NoError(t, err)
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 5, "client2", 300)
+	require.EqualError(t, err, "the owner of asset asset1 cannot be updated in identity ownership mode, use TransferAsset")
+
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "client2")
+	require.NoError(t, err)
+
+	clientIdentity.GetIDReturns("client1", nil)
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "client2")
+	require.EqualError(t, err, "the client is not authorized to change the asset asset1, only its owner can")
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
+	require.EqualError(t, err, "the client is not authorized to change the asset asset1, only its owner can")
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 5, "client2", 300)
+	require.EqualError(t, err, "the client is not authorized to change the asset asset1, only its owner can")
+
+	clientIdentity.GetMSPIDReturns("AdminMSP", nil)
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+}
+
+func TestPermissiveOwnership(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	worldState := stubWorldState(chaincodeStub)
+	clientIdentity := &mocks.ClientIdentity{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext.GetClientIdentityReturns(clientIdentity)
+
+	asset := &chaincode.Asset{ID: "asset1", Owner: "Tomoko"}
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+	worldState["asset1"] = bytes
+
+	// The mode is permissive until an ownership config is set
+	assetTransfer := chaincode.SmartContract{}
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "Brad")
+	require.NoError(t, err)
+
+	initOwnership(t, chaincodeStub, "permissive", "AdminMSP")
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "Max")
+	require.NoError(t, err)
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "blue", 5, "Brad", 300)
+	require.NoError(t, err)
+	require.Equal(t, 0, clientIdentity.GetIDCallCount())
+}
+
+func TestAssetEvents(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetTxIDReturns("tx1")
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	requireEvent := func(call int, expected chaincode.AssetEvent) {
+		eventName, eventBytes := chaincodeStub.SetEventArgsForCall(call)
+		require.Equal(t, expected.Type, eventName)
+		var event chaincode.AssetEvent
+		require.NoError(t, json.Unmarshal(eventBytes, &event))
+		require.Equal(t, expected, event)
+	}
+
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.CreateAsset(transactionContext, "asset1", "blue", 5, "Tomoko", 300)
+	require.NoError(t, err)
+	created := &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, Version: 1}
+	requireEvent(0, chaincode.AssetEvent{Version: 1, Type: "AssetCreated", ID: "asset1", TxID: "tx1", After: created})
+
+	bytes, err := json.Marshal(created)
+	require.NoError(t, err)
+	chaincodeStub.GetStateReturns(bytes, nil)
+
+	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "red", 5, "Tomoko", 300)
+	require.NoError(t, err)
+	updated := &chaincode.Asset{ID: "asset1", Color: "red", Size: 5, Owner: "Tomoko", AppraisedValue: 300, Version: 2}
+	requireEvent(1, chaincode.AssetEvent{Version: 1, Type: "AssetUpdated", ID: "asset1", TxID: "tx1", Before: created, After: updated})
+
+	err = assetTransfer.TransferAsset(transactionContext, "asset1", "Brad")
+	require.NoError(t, err)
+	transferred := &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Brad", AppraisedValue: 300, Version: 2}
+	requireEvent(2, chaincode.AssetEvent{Version: 1, Type: "AssetTransferred", ID: "asset1", TxID: "tx1", Before: created, After: transferred})
+
+	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
+	require.NoError(t, err)
+	requireEvent(3, chaincode.AssetEvent{Version: 1, Type: "AssetDeleted", ID: "asset1", TxID: "tx1", Before: created})
+}
+
 func TestGetAllAssets(t *testing.T) {
 	asset := &chaincode.Asset{ID: "asset1"}
 	bytes, err := json.Marshal(asset)
@@ -182,3 +356,38 @@ func TestGetAllAssets(t *testing.T) {
 	require.EqualError(t, err, "failed retrieving all assets")
 	require.Nil(t, assets)
 }
+
+func TestGetAssetsByRange(t *testing.T) {
+	asset := &chaincode.Asset{ID: "asset1"}
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturnsOnCall(0, true)
+	iterator.HasNextReturnsOnCall(1, false)
+	iterator.NextReturns(&queryresult.KV{Value: bytes}, nil)
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(iterator, &peer.QueryResponseMetadata{FetchedRecordsCount: 1, Bookmark: "asset2"}, nil)
+	assetTransfer := &chaincode.SmartContract{}
+	page, err := assetTransfer.GetAssetsByRange(transactionContext, "asset1", "asset9", 1, "")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.PaginatedQueryResult{Records: []*chaincode.Asset{asset}, FetchedRecordsCount: 1, Bookmark: "asset2"}, page)
+
+	startKey, endKey, pageSize, bookmark := chaincodeStub.GetStateByRangeWithPaginationArgsForCall(0)
+	require.Equal(t, "asset1", startKey)
+	require.Equal(t, "asset9", endKey)
+	require.Equal(t, int32(1), pageSize)
+	require.Equal(t, "", bookmark)
+
+	_, err = assetTransfer.GetAssetsPage(transactionContext, 0, "")
+	require.EqualError(t, err, "pageSize must be a positive integer")
+
+	chaincodeStub.GetStateByRangeWithPaginationReturns(nil, nil, fmt.Errorf("failed retrieving assets"))
+	page, err = assetTransfer.GetAssetsPage(transactionContext, 10, "asset2")
+	require.EqualError(t, err, "failed retrieving assets")
+	require.Nil(t, page)
+}
@@ -0,0 +1,133 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// tagIndex is the name of the composite key index of assets by tag.
+// Its entries are keyed by tag and asset ID, so that the assets of a tag can be found with
+// a partial composite key query on any state database.
+const tagIndex = "tag~id"
+
+// AddTag adds a tag to an asset. Adding a tag the asset already has is an error.
+func (s *SmartContract) AddTag(ctx contractapi.TransactionContextInterface, id string, tag string) error {
+	original, err := s.readAuthorizedAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range original.Tags {
+		if existing == tag {
+			return fmt.Errorf("the asset %s already has the tag %s", id, tag)
+		}
+	}
+
+	asset := *original
+	asset.Tags = append(append([]string{}, original.Tags...), tag)
+	err = putUpdatedAsset(ctx, original, &asset)
+	if err != nil {
+		return err
+	}
+
+	tagIndexKey, err := ctx.GetStub().CreateCompositeKey(tagIndex, []string{tag, id})
+	if err != nil {
+		return err
+	}
+	// Only the key is needed, a nil value would delete the key so a null character is stored
+	return ctx.GetStub().PutState(tagIndexKey, []byte{0x00})
+}
+
+// RemoveTag removes a tag from an asset. Removing a tag the asset does not have is an error.
+func (s *SmartContract) RemoveTag(ctx contractapi.TransactionContextInterface, id string, tag string) error {
+	original, err := s.readAuthorizedAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	asset := *original
+	asset.Tags = nil
+	for _, existing := range original.Tags {
+		if existing != tag {
+			asset.Tags = append(asset.Tags, existing)
+		}
+	}
+	if len(asset.Tags) == len(original.Tags) {
+		return fmt.Errorf("the asset %s does not have the tag %s", id, tag)
+	}
+
+	err = putUpdatedAsset(ctx, original, &asset)
+	if err != nil {
+		return err
+	}
+
+	return deleteTagIndexEntry(ctx, tag, id)
+}
+
+// SetAttribute sets an attribute of an asset. An empty value removes the attribute.
+func (s *SmartContract) SetAttribute(ctx contractapi.TransactionContextInterface, id string, key string, value string) error {
+	original, err := s.readAuthorizedAsset(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	asset := *original
+	asset.Attributes = map[string]string{}
+	for existingKey, existingValue := range original.Attributes {
+		asset.Attributes[existingKey] = existingValue
+	}
+	if value == "" {
+		delete(asset.Attributes, key)
+	} else {
+		asset.Attributes[key] = value
+	}
+	if len(asset.Attributes) == 0 {
+		asset.Attributes = nil
+	}
+
+	return putUpdatedAsset(ctx, original, &asset)
+}
+
+// QueryAssetsByTag returns the assets with a tag, using the tag~id index
+func (s *SmartContract) QueryAssetsByTag(ctx contractapi.TransactionContextInterface, tag string) ([]*Asset, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(tagIndex, []string{tag})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var assets []*Asset
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+
+		asset, err := s.ReadAsset(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// deleteTagIndexEntry removes the tag~id index entry of an asset
+func deleteTagIndexEntry(ctx contractapi.TransactionContextInterface, tag string, id string) error {
+	tagIndexKey, err := ctx.GetStub().CreateCompositeKey(tagIndex, []string{tag, id})
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().DelState(tagIndexKey)
+}
@@ -0,0 +1,119 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func prepTaggedAsset(t *testing.T, chaincodeStub *mocks.ChaincodeStub, asset *chaincode.Asset) {
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+	chaincodeStub.GetStateReturns(bytes, nil)
+	chaincodeStub.CreateCompositeKeyStub = func(objectType string, attributes []string) (string, error) {
+		return objectType + attributes[0] + attributes[1], nil
+	}
+}
+
+func requirePutAsset(t *testing.T, chaincodeStub *mocks.ChaincodeStub, call int) *chaincode.Asset {
+	key, bytes := chaincodeStub.PutStateArgsForCall(call)
+	require.Equal(t, "asset1", key)
+	var asset chaincode.Asset
+	require.NoError(t, json.Unmarshal(bytes, &asset))
+	return &asset
+}
+
+func TestAddTag(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	prepTaggedAsset(t, chaincodeStub, &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, Version: 1, Tags: []string{"red"}})
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.AddTag(transactionContext, "asset1", "fragile")
+	require.NoError(t, err)
+
+	asset := requirePutAsset(t, chaincodeStub, 0)
+	require.Equal(t, []string{"red", "fragile"}, asset.Tags)
+	require.Equal(t, 2, asset.Version)
+	indexKey, value := chaincodeStub.PutStateArgsForCall(1)
+	require.Equal(t, "tag~idfragileasset1", indexKey)
+	require.Equal(t, []byte{0x00}, value)
+
+	err = assetTransfer.AddTag(transactionContext, "asset1", "red")
+	require.EqualError(t, err, "the asset asset1 already has the tag red")
+
+	err = assetTransfer.AddTag(transactionContext, "asset1", "")
+	require.EqualError(t, err, "the asset asset1 is invalid: tags must not be empty")
+}
+
+func TestRemoveTag(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	prepTaggedAsset(t, chaincodeStub, &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, Version: 1, Tags: []string{"red", "fragile"}})
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.RemoveTag(transactionContext, "asset1", "red")
+	require.NoError(t, err)
+
+	asset := requirePutAsset(t, chaincodeStub, 0)
+	require.Equal(t, []string{"fragile"}, asset.Tags)
+	require.Equal(t, "tag~idredasset1", chaincodeStub.DelStateArgsForCall(0))
+
+	err = assetTransfer.RemoveTag(transactionContext, "asset1", "heavy")
+	require.EqualError(t, err, "the asset asset1 does not have the tag heavy")
+}
+
+func TestSetAttribute(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	prepTaggedAsset(t, chaincodeStub, &chaincode.Asset{ID: "asset1", Color: "blue", Size: 5, Owner: "Tomoko", AppraisedValue: 300, Version: 1, Attributes: map[string]string{"origin": "Japan"}})
+	assetTransfer := chaincode.SmartContract{}
+	err := assetTransfer.SetAttribute(transactionContext, "asset1", "material", "wood")
+	require.NoError(t, err)
+	asset := requirePutAsset(t, chaincodeStub, 0)
+	require.Equal(t, map[string]string{"origin": "Japan", "material": "wood"}, asset.Attributes)
+
+	err = assetTransfer.SetAttribute(transactionContext, "asset1", "origin", "")
+	require.NoError(t, err)
+	asset = requirePutAsset(t, chaincodeStub, 1)
+	require.Nil(t, asset.Attributes)
+
+	err = assetTransfer.SetAttribute(transactionContext, "asset1", "", "wood")
+	require.EqualError(t, err, "the asset asset1 is invalid: attributes must not have an empty key")
+}
+
+func TestQueryAssetsByTag(t *testing.T) {
+	asset := &chaincode.Asset{ID: "asset1", Tags: []string{"fragile"}}
+	bytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturnsOnCall(0, true)
+	iterator.HasNextReturnsOnCall(1, false)
+	iterator.NextReturns(&queryresult.KV{Key: "tag~idfragileasset1"}, nil)
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(iterator, nil)
+	chaincodeStub.SplitCompositeKeyReturns("tag~id", []string{"fragile", "asset1"}, nil)
+	chaincodeStub.GetStateReturns(bytes, nil)
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	assetTransfer := chaincode.SmartContract{}
+	assets, err := assetTransfer.QueryAssetsByTag(transactionContext, "fragile")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset}, assets)
+
+	objectType, attributes := chaincodeStub.GetStateByPartialCompositeKeyArgsForCall(0)
+	require.Equal(t, "tag~id", objectType)
+	require.Equal(t, []string{"fragile"}, attributes)
+	require.Equal(t, "asset1", chaincodeStub.GetStateArgsForCall(0))
+}
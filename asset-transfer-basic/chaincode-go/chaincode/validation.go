@@ -0,0 +1,131 @@
+package chaincode
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxIDLength is the maximum length of an asset ID
+	maxIDLength = 64
+	// maxFieldLength is the maximum length of the other string fields of an asset.
+	// It leaves room for client identities, which are used as owner in IdentityOwnership mode.
+	maxFieldLength = 512
+)
+
+// idPattern is the format of asset IDs: letters, digits, '.', '_' and '-', starting with a letter or digit
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// FieldError describes why the value of an asset field is invalid
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError lists all the invalid fields of an asset
+type ValidationError struct {
+	ID     string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	var fields []string
+	for _, field := range e.Fields {
+		fields = append(fields, fmt.Sprintf("%s %s", field.Field, field.Message))
+	}
+	if e.ID == "" {
+		return fmt.Sprintf("the asset is invalid: %s", strings.Join(fields, "; "))
+	}
+	return fmt.Sprintf("the asset %s is invalid: %s", e.ID, strings.Join(fields, "; "))
+}
+
+// validateAsset checks all the fields of an asset, and returns a ValidationError listing
+// the invalid ones, if any
+func validateAsset(asset *Asset) error {
+	var fields []FieldError
+	invalid := func(field, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch {
+	case asset.ID == "":
+		invalid("ID", "must not be empty")
+	case len(asset.ID) > maxIDLength:
+		invalid("ID", "must be at most %d characters long", maxIDLength)
+	case !idPattern.MatchString(asset.ID):
+		invalid("ID", "must only contain letters, digits, '.', '_' and '-', and start with a letter or digit")
+	}
+
+	if len(asset.Color) > maxFieldLength {
+		invalid("color", "must be at most %d characters long", maxFieldLength)
+	}
+
+	if asset.Size <= 0 {
+		invalid("size", "must be positive")
+	}
+
+	if message := ownerError(asset.Owner); message != "" {
+		invalid("owner", "%s", message)
+	}
+
+	if asset.AppraisedValue <= 0 {
+		invalid("appraisedValue", "must be positive")
+	}
+
+	// Attributes are checked in key order, so that errors are the same on all peers
+	keys := make([]string, 0, len(asset.Attributes))
+	for key := range asset.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := asset.Attributes[key]
+		switch {
+		case key == "":
+			invalid("attributes", "must not have an empty key")
+		case len(key) > maxFieldLength || len(value) > maxFieldLength:
+			invalid("attributes", "must have keys and values of at most %d characters", maxFieldLength)
+		}
+	}
+
+	// Tags are attributes of composite keys, which cannot contain null characters
+	for _, tag := range asset.Tags {
+		switch {
+		case tag == "":
+			invalid("tags", "must not be empty")
+		case len(tag) > maxFieldLength:
+			invalid("tags", "must be at most %d characters long", maxFieldLength)
+		case strings.ContainsRune(tag, 0):
+			invalid("tags", "must not contain null characters")
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{ID: asset.ID, Fields: fields}
+	}
+
+	return nil
+}
+
+// validateOwner checks the new owner of an asset, and returns a ValidationError if it is invalid
+func validateOwner(id string, owner string) error {
+	if message := ownerError(owner); message != "" {
+		return &ValidationError{ID: id, Fields: []FieldError{{Field: "owner", Message: message}}}
+	}
+
+	return nil
+}
+
+// ownerError returns why an owner is invalid, or an empty string if it is valid
+func ownerError(owner string) string {
+	switch {
+	case strings.TrimSpace(owner) == "":
+		return "must not be empty"
+	case len(owner) > maxFieldLength:
+		return fmt.Sprintf("must be at most %d characters long", maxFieldLength)
+	}
+
+	return ""
+}
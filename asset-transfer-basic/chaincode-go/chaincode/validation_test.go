@@ -0,0 +1,135 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+type assetInput struct {
+	id             string
+	color          string
+	size           int
+	owner          string
+	appraisedValue int
+}
+
+var validationTests = []struct {
+	name          string
+	input         assetInput
+	invalidFields []string
+	expectedError string
+}{
+	{
+		name:  "valid asset",
+		input: assetInput{"asset1", "blue", 5, "Tomoko", 300},
+	},
+	{
+		name:          "empty ID",
+		input:         assetInput{"", "blue", 5, "Tomoko", 300},
+		invalidFields: []string{"ID"},
+		expectedError: "the asset is invalid: ID must not be empty",
+	},
+	{
+		name:          "ID format",
+		input:         assetInput{"-asset 1", "blue", 5, "Tomoko", 300},
+		invalidFields: []string{"ID"},
+		expectedError: "the asset -asset 1 is invalid: ID must only contain letters, digits, '.', '_' and '-', and start with a letter or digit",
+	},
+	{
+		name:          "ID too long",
+		input:         assetInput{strings.Repeat("a", 65), "blue", 5, "Tomoko", 300},
+		invalidFields: []string{"ID"},
+	},
+	{
+		name:          "color too long",
+		input:         assetInput{"asset1", strings.Repeat("b", 513), 5, "Tomoko", 300},
+		invalidFields: []string{"color"},
+		expectedError: "the asset asset1 is invalid: color must be at most 512 characters long",
+	},
+	{
+		name:          "negative size",
+		input:         assetInput{"asset1", "blue", -5, "Tomoko", 300},
+		invalidFields: []string{"size"},
+		expectedError: "the asset asset1 is invalid: size must be positive",
+	},
+	{
+		name:          "blank owner",
+		input:         assetInput{"asset1", "blue", 5, "  ", 300},
+		invalidFields: []string{"owner"},
+		expectedError: "the asset asset1 is invalid: owner must not be empty",
+	},
+	{
+		name:          "zero appraised value",
+		input:         assetInput{"asset1", "blue", 5, "Tomoko", 0},
+		invalidFields: []string{"appraisedValue"},
+		expectedError: "the asset asset1 is invalid: appraisedValue must be positive",
+	},
+	{
+		name:          "all fields invalid",
+		input:         assetInput{"", "blue", 0, "", -1},
+		invalidFields: []string{"ID", "size", "owner", "appraisedValue"},
+		expectedError: "the asset is invalid: ID must not be empty; size must be positive; owner must not be empty; appraisedValue must be positive",
+	},
+}
+
+func requireValidationError(t *testing.T, err error, invalidFields []string, expectedError string) {
+	if len(invalidFields) == 0 {
+		require.NoError(t, err)
+		return
+	}
+
+	var validationError *chaincode.ValidationError
+	require.True(t, errors.As(err, &validationError), "expected a validation error, got %v", err)
+	var fields []string
+	for _, field := range validationError.Fields {
+		fields = append(fields, field.Field)
+	}
+	require.Equal(t, invalidFields, fields)
+	if expectedError != "" {
+		require.EqualError(t, err, expectedError)
+	}
+}
+
+func TestCreateAssetValidation(t *testing.T) {
+	for _, test := range validationTests {
+		t.Run(test.name, func(t *testing.T) {
+			chaincodeStub := &mocks.ChaincodeStub{}
+			transactionContext := &mocks.TransactionContext{}
+			transactionContext.GetStubReturns(chaincodeStub)
+
+			assetTransfer := chaincode.SmartContract{}
+			err := assetTransfer.CreateAsset(transactionContext, test.input.id, test.input.color, test.input.size, test.input.owner, test.input.appraisedValue)
+			requireValidationError(t, err, test.invalidFields, test.expectedError)
+			if err != nil {
+				require.Equal(t, 0, chaincodeStub.PutStateCallCount())
+			}
+		})
+	}
+}
+
+func TestUpdateAssetValidation(t *testing.T) {
+	for _, test := range validationTests {
+		t.Run(test.name, func(t *testing.T) {
+			bytes, err := json.Marshal(&chaincode.Asset{ID: test.input.id, Version: 1})
+			require.NoError(t, err)
+
+			chaincodeStub := &mocks.ChaincodeStub{}
+			chaincodeStub.GetStateReturns(bytes, nil)
+			transactionContext := &mocks.TransactionContext{}
+			transactionContext.GetStubReturns(chaincodeStub)
+
+			assetTransfer := chaincode.SmartContract{}
+			err = assetTransfer.UpdateAsset(transactionContext, test.input.id, test.input.color, test.input.size, test.input.owner, test.input.appraisedValue)
+			requireValidationError(t, err, test.invalidFields, test.expectedError)
+			if err != nil {
+				require.Equal(t, 0, chaincodeStub.PutStateCallCount())
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// aggregatePageSize is the number of assets read per page while aggregating,
+// so that only one page of assets is held in memory at a time
+const aggregatePageSize = 100
+
+// AggregateResult structure used for returning the metric of one group of assets
+type AggregateResult struct {
+	Group string  `json:"group"`
+	Count int     `json:"count"`
+	Value float64 `json:"value"`
+}
+
+// aggregateGroupFields are the asset fields that assets can be grouped by
+var aggregateGroupFields = map[string]func(*Asset) string{
+	"owner":   func(asset *Asset) string { return asset.Owner },
+	"color":   func(asset *Asset) string { return asset.Color },
+	"size":    func(asset *Asset) string { return strconv.Itoa(asset.Size) },
+	"docType": func(asset *Asset) string { return asset.DocType },
+}
+
+// aggregateMetricFields are the numeric asset fields that metrics can be computed on
+var aggregateMetricFields = map[string]func(*Asset) int{
+	"size":           func(asset *Asset) int { return asset.Size },
+	"appraisedValue": func(asset *Asset) int { return asset.AppraisedValue },
+}
+
+// aggregate accumulates the metric of a group of assets
+type aggregate struct {
+	count int
+	sum   int
+	min   int
+	max   int
+}
+
+func (a *aggregate) add(value int) {
+	if a.count == 0 || value < a.min {
+		a.min = value
+	}
+	if a.count == 0 || value > a.max {
+		a.max = value
+	}
+	a.count++
+	a.sum += value
+}
+
+func (a *aggregate) value(function string) float64 {
+	switch function {
+	case "sum":
+		return float64(a.sum)
+	case "avg":
+		return float64(a.sum) / float64(a.count)
+	case "min":
+		return float64(a.min)
+	case "max":
+		return float64(a.max)
+	default:
+		return float64(a.count)
+	}
+}
+
+// AggregateAssets computes a metric over all assets, grouped by an asset field.
+// groupBy is one of owner, color, size or docType, or empty to aggregate all assets in one group.
+// metric is count, or one of sum, avg, min or max applied to size or appraisedValue,
+// e.g. sum(appraisedValue). Results are ordered by group.
+// Assets are read page by page, with a rich query if the state database supports it and a
+// range query otherwise. Paginated queries are only valid for read only transactions.
+// Example: Aggregation with paginated queries
+func (t *SimpleChaincode) AggregateAssets(ctx contractapi.TransactionContextInterface, groupBy string, metric string) ([]AggregateResult, error) {
+	groupValue := func(*Asset) string { return "" }
+	if groupBy != "" {
+		var ok bool
+		groupValue, ok = aggregateGroupFields[groupBy]
+		if !ok {
+			return nil, fmt.Errorf("cannot group by field %q, use one of owner, color, size or docType", groupBy)
+		}
+	}
+
+	function, metricValue, err := parseAggregateMetric(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates := map[string]*aggregate{}
+	err = forEachAsset(ctx, func(asset *Asset) {
+		group := groupValue(asset)
+		if aggregates[group] == nil {
+			aggregates[group] = &aggregate{}
+		}
+		aggregates[group].add(metricValue(asset))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []AggregateResult{}
+	for group, groupAggregate := range aggregates {
+		results = append(results, AggregateResult{
+			Group: group,
+			Count: groupAggregate.count,
+			Value: groupAggregate.value(function),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Group < results[j].Group
+	})
+
+	return results, nil
+}
+
+// parseAggregateMetric splits a metric such as sum(appraisedValue) into its function and field
+func parseAggregateMetric(metric string) (string, func(*Asset) int, error) {
+	if metric == "count" || metric == "count()" {
+		return "count", func(*Asset) int { return 0 }, nil
+	}
+
+	open := strings.Index(metric, "(")
+	if open < 0 || !strings.HasSuffix(metric, ")") {
+		return "", nil, fmt.Errorf("invalid metric %q, expected count or a function of a field, e.g. sum(appraisedValue)", metric)
+	}
+	function, field := metric[:open], metric[open+1:len(metric)-1]
+
+	switch function {
+	case "count", "sum", "avg", "min", "max":
+	default:
+		return "", nil, fmt.Errorf("unknown function %q in metric, use one of count, sum, avg, min or max", function)
+	}
+	metricValue, ok := aggregateMetricFields[field]
+	if !ok {
+		return "", nil, fmt.Errorf("cannot compute %s of field %q, use size or appraisedValue", function, field)
+	}
+
+	return function, metricValue, nil
+}
+
+// forEachAsset calls fn for each asset in the world state, reading aggregatePageSize assets at a time
+func forEachAsset(ctx contractapi.TransactionContextInterface, fn func(*Asset)) error {
+	queryString := `{"selector":{"docType":"asset"}}`
+	fetchPage := func(bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+		return ctx.GetStub().GetQueryResultWithPagination(queryString, aggregatePageSize, bookmark)
+	}
+
+	bookmark := ""
+	for {
+		resultsIterator, responseMetadata, err := fetchPage(bookmark)
+		if err != nil && bookmark == "" && isRichQueryUnsupported(err) {
+			// Asset keys are simple keys, so a range query on all simple keys returns the assets
+			fetchPage = func(bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+				return ctx.GetStub().GetStateByRangeWithPagination("", "", aggregatePageSize, bookmark)
+			}
+			resultsIterator, responseMetadata, err = fetchPage(bookmark)
+		}
+		if err != nil {
+			return err
+		}
+
+		for resultsIterator.HasNext() {
+			queryResult, err := resultsIterator.Next()
+			if err != nil {
+				resultsIterator.Close()
+				return err
+			}
+			asset, err := unmarshalAsset(queryResult.Value)
+			if err != nil {
+				resultsIterator.Close()
+				return fmt.Errorf("failed to unmarshal %s: %v", queryResult.Key, err)
+			}
+			if asset.DocType == "asset" {
+				fn(asset)
+			}
+		}
+		resultsIterator.Close()
+
+		// The last page is shorter than a full page. Range queries also return an empty
+		// bookmark once there are no more keys.
+		if responseMetadata.FetchedRecordsCount < aggregatePageSize || responseMetadata.Bookmark == "" {
+			return nil
+		}
+		bookmark = responseMetadata.Bookmark
+	}
+}
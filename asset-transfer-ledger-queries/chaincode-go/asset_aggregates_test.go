@@ -0,0 +1,97 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAggregateMetric(t *testing.T) {
+	asset := &Asset{Size: 5, AppraisedValue: 35}
+
+	tests := []struct {
+		metric   string
+		function string
+		value    int
+		err      string
+	}{
+		{metric: "count", function: "count"},
+		{metric: "count()", function: "count"},
+		{metric: "count(size)", function: "count", value: 5},
+		{metric: "sum(appraisedValue)", function: "sum", value: 35},
+		{metric: "avg(size)", function: "avg", value: 5},
+		{metric: "min(size)", function: "min", value: 5},
+		{metric: "max(appraisedValue)", function: "max", value: 35},
+		{
+			metric: "sum",
+			err:    `invalid metric "sum", expected count or a function of a field, e.g. sum(appraisedValue)`,
+		},
+		{
+			metric: "sum(size",
+			err:    `invalid metric "sum(size", expected count or a function of a field, e.g. sum(appraisedValue)`,
+		},
+		{
+			metric: "median(size)",
+			err:    `unknown function "median" in metric, use one of count, sum, avg, min or max`,
+		},
+		{
+			metric: "sum(owner)",
+			err:    `cannot compute sum of field "owner", use size or appraisedValue`,
+		},
+		{
+			metric: "sum()",
+			err:    `cannot compute sum of field "", use size or appraisedValue`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.metric, func(t *testing.T) {
+			function, metricValue, err := parseAggregateMetric(test.metric)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.function, function)
+			require.Equal(t, test.value, metricValue(asset))
+		})
+	}
+}
+
+func TestAggregateAssetsOnLevelDB(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+
+	// One more asset than a page, so that the assets are read in two pages
+	for i := 0; i <= aggregatePageSize; i++ {
+		owner := "tom"
+		if i%2 == 1 {
+			owner = "jerry"
+		}
+		require.NoError(t, chaincode.CreateAsset(ctx, fmt.Sprintf("asset%03d", i), "blue", i, owner, 10))
+	}
+
+	results, err := chaincode.AggregateAssets(ctx, "", "count")
+	require.NoError(t, err)
+	require.Equal(t, []AggregateResult{{Group: "", Count: 101, Value: 101}}, results)
+
+	results, err = chaincode.AggregateAssets(ctx, "owner", "max(size)")
+	require.NoError(t, err)
+	require.Equal(t, []AggregateResult{
+		{Group: "jerry", Count: 50, Value: 99},
+		{Group: "tom", Count: 51, Value: 100},
+	}, results)
+
+	results, err = chaincode.AggregateAssets(ctx, "owner", "avg(size)")
+	require.NoError(t, err)
+	require.Equal(t, 50.0, results[0].Value)
+	require.Equal(t, 50.0, results[1].Value)
+
+	_, err = chaincode.AggregateAssets(ctx, "ID", "count")
+	require.EqualError(t, err, `cannot group by field "ID", use one of owner, color, size or docType`)
+}
@@ -0,0 +1,273 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// bulkUpdateEvent is the name of the chaincode event emitted by each BulkUpdate batch
+const bulkUpdateEvent = "BulkUpdateBatch"
+
+// patchFields are the asset fields that BulkUpdate can change
+var patchFields = map[string]bool{
+	"color":          true,
+	"size":           true,
+	"owner":          true,
+	"appraisedValue": true,
+}
+
+// BulkUpdateResult structure used for returning the summary of a BulkUpdate batch.
+// It is also the payload of the BulkUpdateBatch event.
+type BulkUpdateResult struct {
+	TxId         string   `json:"txId"`
+	UpdatedCount int      `json:"updatedCount"`
+	AssetIDs     []string `json:"assetIDs"`
+	Bookmark     string   `json:"bookmark"`
+}
+
+// BulkUpdate applies a patch to the assets matching a filter, at most maxRecords assets per
+// transaction. The filter is a JSON array of filters, as in SearchAssets, and an empty array
+// matches all assets. The patch is a JSON object of the fields to set, among color, size,
+// owner and appraisedValue.
+// Assets are processed in ID order. The returned bookmark is the ID of the last updated asset,
+// to be passed to the next call to continue; it is empty once all assets were processed.
+// An equality filter on color or owner is answered with the matching index, other filters
+// are evaluated while reading all assets with a range query.
+// Range queries are re-executed by committing peers, see TransferAssetByColor.
+// Example: Batched update with a range query
+func (t *SimpleChaincode) BulkUpdate(ctx contractapi.TransactionContextInterface, filter string, patch string, maxRecords int, bookmark string) (*BulkUpdateResult, error) {
+	if maxRecords <= 0 {
+		return nil, fmt.Errorf("maxRecords must be a positive integer")
+	}
+
+	var filters []QueryFilter
+	err := json.Unmarshal([]byte(filter), &filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal filter: %v", err)
+	}
+	// Check the filters once, before any asset is read
+	for _, queryFilter := range filters {
+		_, err = matchesFilter(&Asset{}, queryFilter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var patchValues map[string]json.RawMessage
+	err = json.Unmarshal([]byte(patch), &patchValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal patch: %v", err)
+	}
+	if len(patchValues) == 0 {
+		return nil, fmt.Errorf("patch must set at least one field")
+	}
+	for name := range patchValues {
+		if !patchFields[name] {
+			return nil, fmt.Errorf("cannot patch field %q, use color, size, owner or appraisedValue", name)
+		}
+	}
+	err = json.Unmarshal([]byte(patch), &Asset{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch: %v", err)
+	}
+
+	resultsIterator, err := bulkUpdateIterator(ctx, filters, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &BulkUpdateResult{TxId: ctx.GetStub().GetTxID(), AssetIDs: []string{}}
+	for resultsIterator.HasNext() {
+		if result.UpdatedCount == maxRecords {
+			result.Bookmark = result.AssetIDs[len(result.AssetIDs)-1]
+			break
+		}
+
+		asset, err := t.nextBulkUpdateAsset(ctx, resultsIterator, bookmark)
+		if err != nil {
+			return nil, err
+		}
+		if asset == nil {
+			continue
+		}
+
+		matches := true
+		for _, queryFilter := range filters {
+			matches, err = matchesFilter(asset, queryFilter)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		err = patchAsset(ctx, asset, []byte(patch))
+		if err != nil {
+			return nil, fmt.Errorf("update failed for asset %s: %v", asset.ID, err)
+		}
+		result.UpdatedCount++
+		result.AssetIDs = append(result.AssetIDs, asset.ID)
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	err = ctx.GetStub().SetEvent(bulkUpdateEvent, resultJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// bulkUpdateIterator returns an iterator on the color~name or owner~name index if the filters
+// include an equality filter on color or owner, and on all simple keys after the bookmark otherwise
+func bulkUpdateIterator(ctx contractapi.TransactionContextInterface, filters []QueryFilter, bookmark string) (shim.StateQueryIteratorInterface, error) {
+	indexNames := map[string]string{"color": index, "owner": ownerIndex}
+	for _, queryFilter := range filters {
+		indexName, ok := indexNames[queryFilter.Field]
+		if !ok || queryFilter.Op != "eq" {
+			continue
+		}
+		var value string
+		err := json.Unmarshal(queryFilter.Value, &value)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.GetStub().GetStateByPartialCompositeKey(indexName, []string{value})
+	}
+
+	startKey := ""
+	if bookmark != "" {
+		startKey = bookmark + "\x00"
+	}
+	return ctx.GetStub().GetStateByRange(startKey, "")
+}
+
+// nextBulkUpdateAsset reads the asset of the next index entry or state entry of the iterator.
+// It returns nil for entries that are not assets or that are not after the bookmark.
+func (t *SimpleChaincode) nextBulkUpdateAsset(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface, bookmark string) (*Asset, error) {
+	queryResult, err := resultsIterator.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(queryResult.Key, "\x00") {
+		asset, err := unmarshalAsset(queryResult.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %v", queryResult.Key, err)
+		}
+		if asset.DocType != "asset" {
+			return nil, nil
+		}
+		return asset, nil
+	}
+
+	_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(queryResult.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(compositeKeyParts) < 2 || compositeKeyParts[1] <= bookmark {
+		return nil, nil
+	}
+
+	return t.ReadAsset(ctx, compositeKeyParts[1])
+}
+
+// patchAsset sets the patched fields on the asset, saves it and updates its index entries
+func patchAsset(ctx contractapi.TransactionContextInterface, asset *Asset, patch []byte) error {
+	err := deleteAssetIndexes(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(patch, asset)
+	if err != nil {
+		return err
+	}
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	return putAssetIndexes(ctx, asset)
+}
+
+// matchesFilter evaluates a SearchAssets filter against an asset
+func matchesFilter(asset *Asset, filter QueryFilter) (bool, error) {
+	field, ok := queryFields[filter.Field]
+	if !ok {
+		return false, fmt.Errorf("cannot filter on field %q, filters are allowed on: %s", filter.Field, queryFieldNames())
+	}
+	if _, ok := queryOperators[filter.Op]; !ok {
+		return false, fmt.Errorf("unknown operator %q on field %s, use one of eq, ne, gt, lt or in", filter.Op, filter.Field)
+	}
+
+	value, err := filterValue(filter, field, filter.Op == "in")
+	if err != nil {
+		return false, err
+	}
+
+	compare := func(cmp int) bool {
+		switch filter.Op {
+		case "eq":
+			return cmp == 0
+		case "ne":
+			return cmp != 0
+		case "gt":
+			return cmp > 0
+		default:
+			return cmp < 0
+		}
+	}
+
+	switch value := value.(type) {
+	case *int:
+		actual := aggregateMetricFields[filter.Field](asset)
+		return compare(compareInts(actual, *value)), nil
+	case *[]int:
+		actual := aggregateMetricFields[filter.Field](asset)
+		for _, candidate := range *value {
+			if actual == candidate {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *string:
+		actual := aggregateGroupFields[filter.Field](asset)
+		return compare(strings.Compare(actual, *value)), nil
+	default:
+		actual := aggregateGroupFields[filter.Field](asset)
+		for _, candidate := range *value.(*[]string) {
+			if actual == candidate {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
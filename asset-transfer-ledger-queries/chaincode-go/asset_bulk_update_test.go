@@ -0,0 +1,180 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchesFilter(t *testing.T) {
+	asset := &Asset{DocType: "asset", ID: "asset1", Color: "blue", Size: 5, Owner: "tom", AppraisedValue: 35}
+
+	tests := []struct {
+		filter   string
+		expected bool
+		err      string
+	}{
+		{filter: `{"field":"owner","op":"eq","value":"tom"}`, expected: true},
+		{filter: `{"field":"owner","op":"eq","value":"jerry"}`, expected: false},
+		{filter: `{"field":"owner","op":"ne","value":"jerry"}`, expected: true},
+		{filter: `{"field":"color","op":"gt","value":"azure"}`, expected: true},
+		{filter: `{"field":"color","op":"lt","value":"azure"}`, expected: false},
+		{filter: `{"field":"color","op":"in","value":["red","blue"]}`, expected: true},
+		{filter: `{"field":"color","op":"in","value":["red"]}`, expected: false},
+		{filter: `{"field":"size","op":"eq","value":5}`, expected: true},
+		{filter: `{"field":"size","op":"gt","value":5}`, expected: false},
+		{filter: `{"field":"size","op":"lt","value":6}`, expected: true},
+		{filter: `{"field":"appraisedValue","op":"ne","value":35}`, expected: false},
+		{filter: `{"field":"appraisedValue","op":"in","value":[10,35]}`, expected: true},
+		{filter: `{"field":"appraisedValue","op":"in","value":[]}`, expected: false},
+		{filter: `{"field":"docType","op":"eq","value":"asset"}`, expected: true},
+		{
+			filter: `{"field":"ID","op":"eq","value":"asset1"}`,
+			err:    `cannot filter on field "ID", filters are allowed on: appraisedValue, color, docType, owner, size`,
+		},
+		{
+			filter: `{"field":"size","op":"ge","value":5}`,
+			err:    `unknown operator "ge" on field size, use one of eq, ne, gt, lt or in`,
+		},
+		{
+			filter: `{"field":"size","op":"eq","value":"5"}`,
+			err:    `invalid value "5" for field size, expected an integer`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			var filter QueryFilter
+			require.NoError(t, json.Unmarshal([]byte(test.filter), &filter))
+
+			matches, err := matchesFilter(asset, filter)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, matches)
+		})
+	}
+}
+
+func TestPatchAsset(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset1", "blue", 5, "tom", 35))
+
+	asset, err := chaincode.ReadAsset(ctx, "asset1")
+	require.NoError(t, err)
+	require.NoError(t, patchAsset(ctx, asset, []byte(`{"owner":"jerry","size":7}`)))
+
+	asset, err = chaincode.ReadAsset(ctx, "asset1")
+	require.NoError(t, err)
+	require.Equal(t, &Asset{DocType: "asset", ID: "asset1", Color: "blue", Size: 7, Owner: "jerry", AppraisedValue: 35, SchemaVersion: currentSchemaVersion}, asset)
+
+	// The index entries follow the patched fields
+	assets, err := chaincode.GetAssetsByOwner(ctx, "tom")
+	require.NoError(t, err)
+	require.Empty(t, assets)
+	assets, err = chaincode.GetAssetsByOwner(ctx, "jerry")
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+	assets, err = chaincode.GetAssetsBySizeRange(ctx, 7, 7)
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+
+	// Patches that fail the asset schema are not saved
+	asset, err = chaincode.ReadAsset(ctx, "asset1")
+	require.NoError(t, err)
+	err = patchAsset(ctx, asset, []byte(`{"size":-1}`))
+	require.Error(t, err)
+	asset, err = chaincode.ReadAsset(ctx, "asset1")
+	require.NoError(t, err)
+	require.Equal(t, 7, asset.Size)
+}
+
+func TestBulkUpdateContinuesFromBookmark(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{name: "range query", filter: `[{"field":"size","op":"gt","value":4}]`},
+		{name: "owner index", filter: `[{"field":"owner","op":"eq","value":"tom"},{"field":"size","op":"gt","value":4}]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub, ctx := newLedgerStub()
+			chaincode := &SimpleChaincode{}
+			require.NoError(t, chaincode.CreateAsset(ctx, "asset1", "blue", 5, "tom", 35))
+			require.NoError(t, chaincode.CreateAsset(ctx, "asset2", "red", 4, "tom", 50))
+			require.NoError(t, chaincode.CreateAsset(ctx, "asset3", "blue", 6, "tom", 70))
+			require.NoError(t, chaincode.CreateAsset(ctx, "asset4", "green", 7, "tom", 80))
+			require.NoError(t, chaincode.CreateAsset(ctx, "asset5", "green", 8, "spike", 90))
+
+			var updatedIDs []string
+			bookmark := ""
+			for batch := 1; ; batch++ {
+				stub.MockTransactionStart(fmt.Sprintf("batch%d", batch))
+				result, err := chaincode.BulkUpdate(ctx, test.filter, `{"appraisedValue":100}`, 2, bookmark)
+				require.NoError(t, err)
+				require.Equal(t, stub.TxID, result.TxId)
+				require.Equal(t, len(result.AssetIDs), result.UpdatedCount)
+				updatedIDs = append(updatedIDs, result.AssetIDs...)
+
+				if result.Bookmark == "" {
+					break
+				}
+				require.Equal(t, result.AssetIDs[len(result.AssetIDs)-1], result.Bookmark)
+				bookmark = result.Bookmark
+			}
+
+			expected := []string{"asset1", "asset3", "asset4"}
+			if test.name == "range query" {
+				expected = append(expected, "asset5")
+			}
+			require.Equal(t, expected, updatedIDs)
+
+			asset, err := chaincode.ReadAsset(ctx, "asset2")
+			require.NoError(t, err)
+			require.Equal(t, 50, asset.AppraisedValue)
+			asset, err = chaincode.ReadAsset(ctx, "asset3")
+			require.NoError(t, err)
+			require.Equal(t, 100, asset.AppraisedValue)
+
+			// Each batch emits its summary
+			var lastBatch BulkUpdateResult
+			require.Equal(t, bulkUpdateEvent, stub.events[len(stub.events)-1].EventName)
+			require.NoError(t, json.Unmarshal(stub.events[len(stub.events)-1].Payload, &lastBatch))
+			require.Equal(t, "", lastBatch.Bookmark)
+		})
+	}
+}
+
+func TestBulkUpdateRejectsInvalidInput(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+
+	tests := []struct {
+		filter     string
+		patch      string
+		maxRecords int
+		err        string
+	}{
+		{filter: `[]`, patch: `{"owner":"jerry"}`, maxRecords: 0, err: "maxRecords must be a positive integer"},
+		{filter: `{}`, patch: `{"owner":"jerry"}`, maxRecords: 1, err: "failed to unmarshal filter: json: cannot unmarshal object into Go value of type []main.QueryFilter"},
+		{filter: `[{"field":"ID","op":"eq","value":"asset1"}]`, patch: `{"owner":"jerry"}`, maxRecords: 1, err: `cannot filter on field "ID", filters are allowed on: appraisedValue, color, docType, owner, size`},
+		{filter: `[]`, patch: `{}`, maxRecords: 1, err: "patch must set at least one field"},
+		{filter: `[]`, patch: `{"ID":"asset2"}`, maxRecords: 1, err: `cannot patch field "ID", use color, size, owner or appraisedValue`},
+		{filter: `[]`, patch: `{"size":"big"}`, maxRecords: 1, err: "invalid patch: json: cannot unmarshal string into Go struct field Asset.size of type int"},
+	}
+	for _, test := range tests {
+		_, err := chaincode.BulkUpdate(ctx, test.filter, test.patch, test.maxRecords, "")
+		require.EqualError(t, err, test.err, test.patch)
+	}
+}
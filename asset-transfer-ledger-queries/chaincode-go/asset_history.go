@@ -0,0 +1,156 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// HistoryDiffResult structure used for returning the changes made by one transaction to an asset
+type HistoryDiffResult struct {
+	TxId      string        `json:"txId"`
+	Timestamp time.Time     `json:"timestamp"`
+	IsDelete  bool          `json:"isDelete"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// FieldChange is the change of a single asset field between two consecutive versions.
+// Values are formatted as strings, an empty OldValue means the field was not set before.
+type FieldChange struct {
+	Field    string `json:"field"`
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+}
+
+// GetAssetHistoryDiff returns the field level changes made to an asset by each transaction,
+// oldest first. The first version, and a version that recreates a deleted asset, are compared
+// to an empty asset. Deletes are reported without changes.
+func (t *SimpleChaincode) GetAssetHistoryDiff(ctx contractapi.TransactionContextInterface, assetID string) ([]HistoryDiffResult, error) {
+	history, err := t.getChronologicalHistory(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []HistoryDiffResult
+	var previous *Asset
+	for _, record := range history {
+		diff := HistoryDiffResult{
+			TxId:      record.TxId,
+			Timestamp: record.Timestamp,
+			IsDelete:  record.IsDelete,
+		}
+		if record.IsDelete {
+			previous = nil
+		} else {
+			diff.Changes = diffAssets(previous, record.Record)
+			previous = record.Record
+		}
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+// GetAssetAsOf returns the asset as it was at the given time, formatted as RFC 3339
+// (e.g. 2021-01-02T15:04:05Z). The state is the last version written at or before that time.
+func (t *SimpleChaincode) GetAssetAsOf(ctx contractapi.TransactionContextInterface, assetID string, timestamp string) (*Asset, error) {
+	asOf, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp %s, expected RFC 3339 format: %v", timestamp, err)
+	}
+
+	history, err := t.getChronologicalHistory(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	var asset *Asset
+	for _, record := range history {
+		if record.Timestamp.After(asOf) {
+			break
+		}
+		if record.IsDelete {
+			asset = nil
+		} else {
+			asset = record.Record
+		}
+	}
+	if asset == nil {
+		return nil, fmt.Errorf("asset %s did not exist at %s", assetID, timestamp)
+	}
+
+	return asset, nil
+}
+
+// getChronologicalHistory returns the history of an asset, oldest version first.
+// GetHistoryForKey returns the versions newest first, in commit order, so they are reversed.
+// Timestamps are set by the clients and may not follow the commit order, they are not sorted.
+func (t *SimpleChaincode) getChronologicalHistory(ctx contractapi.TransactionContextInterface, assetID string) ([]HistoryQueryResult, error) {
+	records, err := t.GetAssetHistory(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	return records, nil
+}
+
+// diffAssets lists the fields that differ between two versions of an asset.
+// A nil previous version is treated as an empty asset.
+func diffAssets(previous, current *Asset) []FieldChange {
+	if previous == nil {
+		previous = &Asset{}
+	}
+
+	oldFields := assetFields(previous)
+	newFields := assetFields(current)
+
+	var changes []FieldChange
+	for i, field := range newFields {
+		if field.value != oldFields[i].value {
+			changes = append(changes, FieldChange{
+				Field:    field.name,
+				OldValue: oldFields[i].value,
+				NewValue: field.value,
+			})
+		}
+	}
+
+	return changes
+}
+
+type assetField struct {
+	name  string
+	value string
+}
+
+// assetFields returns the fields of an asset in a fixed order, named after their JSON tags.
+// Unset fields have an empty value.
+func assetFields(asset *Asset) []assetField {
+	formatInt := func(value int, isSet bool) string {
+		if !isSet {
+			return ""
+		}
+		return strconv.Itoa(value)
+	}
+	isSet := asset.ID != ""
+
+	return []assetField{
+		{"docType", asset.DocType},
+		{"ID", asset.ID},
+		{"color", asset.Color},
+		{"size", formatInt(asset.Size, isSet)},
+		{"owner", asset.Owner},
+		{"appraisedValue", formatInt(asset.AppraisedValue, isSet)},
+		{"schemaVersion", formatInt(asset.SchemaVersion, asset.SchemaVersion != 0)},
+	}
+}
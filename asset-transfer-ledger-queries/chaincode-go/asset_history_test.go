@@ -0,0 +1,143 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffAssets(t *testing.T) {
+	asset := &Asset{DocType: "asset", ID: "asset1", Color: "blue", Size: 0, Owner: "tom", AppraisedValue: 35, SchemaVersion: 2}
+
+	tests := []struct {
+		name     string
+		previous *Asset
+		current  *Asset
+		expected []FieldChange
+	}{
+		{
+			name:    "first version is compared to an empty asset",
+			current: asset,
+			expected: []FieldChange{
+				{Field: "docType", NewValue: "asset"},
+				{Field: "ID", NewValue: "asset1"},
+				{Field: "color", NewValue: "blue"},
+				{Field: "size", NewValue: "0"},
+				{Field: "owner", NewValue: "tom"},
+				{Field: "appraisedValue", NewValue: "35"},
+				{Field: "schemaVersion", NewValue: "2"},
+			},
+		},
+		{
+			name:     "changed fields only",
+			previous: asset,
+			current:  &Asset{DocType: "asset", ID: "asset1", Color: "blue", Size: 3, Owner: "jerry", AppraisedValue: 35, SchemaVersion: 2},
+			expected: []FieldChange{
+				{Field: "size", OldValue: "0", NewValue: "3"},
+				{Field: "owner", OldValue: "tom", NewValue: "jerry"},
+			},
+		},
+		{
+			name:     "migrated version",
+			previous: &Asset{DocType: "asset", ID: "asset1", Color: "blue", Owner: "tom", AppraisedValue: 35},
+			current:  asset,
+			expected: []FieldChange{
+				{Field: "schemaVersion", NewValue: "2"},
+			},
+		},
+		{
+			name:     "unchanged",
+			previous: asset,
+			current:  asset,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, diffAssets(test.previous, test.current))
+		})
+	}
+}
+
+func TestGetChronologicalHistory(t *testing.T) {
+	stub, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	block1 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	block2 := block1.Add(time.Hour)
+	block3 := block2.Add(time.Hour)
+
+	stub.startTransaction("tx1", block1)
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset1", "blue", 5, "tom", 35))
+	stub.startTransaction("tx2", block2)
+	require.NoError(t, chaincode.TransferAsset(ctx, "asset1", "jerry"))
+	// Transactions of the same block share its timestamp
+	stub.startTransaction("tx3", block2)
+	require.NoError(t, chaincode.DeleteAsset(ctx, "asset1"))
+	stub.startTransaction("tx4", block3)
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset1", "red", 6, "spike", 70))
+
+	records, err := chaincode.getChronologicalHistory(ctx, "asset1")
+	require.NoError(t, err)
+	var txIDs []string
+	for _, record := range records {
+		txIDs = append(txIDs, record.TxId)
+	}
+	require.Equal(t, []string{"tx1", "tx2", "tx3", "tx4"}, txIDs)
+
+	diffs, err := chaincode.GetAssetHistoryDiff(ctx, "asset1")
+	require.NoError(t, err)
+	require.Len(t, diffs, 4)
+	require.Equal(t, []FieldChange{{Field: "owner", OldValue: "tom", NewValue: "jerry"}}, diffs[1].Changes)
+	require.True(t, diffs[2].IsDelete)
+	require.Empty(t, diffs[2].Changes)
+	// A recreated asset is compared to an empty asset
+	require.Contains(t, diffs[3].Changes, FieldChange{Field: "color", NewValue: "red"})
+
+	asset, err := chaincode.GetAssetAsOf(ctx, "asset1", block1.Add(time.Minute).Format(time.RFC3339))
+	require.NoError(t, err)
+	require.Equal(t, "tom", asset.Owner)
+
+	_, err = chaincode.GetAssetAsOf(ctx, "asset1", block2.Format(time.RFC3339))
+	require.EqualError(t, err, "asset asset1 did not exist at 2021-01-01T01:00:00Z")
+
+	asset, err = chaincode.GetAssetAsOf(ctx, "asset1", block3.Format(time.RFC3339))
+	require.NoError(t, err)
+	require.Equal(t, "spike", asset.Owner)
+
+	_, err = chaincode.GetAssetAsOf(ctx, "asset1", "2021-01-01")
+	require.Error(t, err)
+}
+
+func TestGetChronologicalHistoryKeepsCommitOrder(t *testing.T) {
+	stub, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Client timestamps that do not follow the commit order
+	var history []*queryresult.KeyModification
+	for i, offset := range []int{2, 0, 1} {
+		asset := &Asset{DocType: "asset", ID: "asset1", Color: "blue", Size: i, Owner: "tom", AppraisedValue: 35, SchemaVersion: 2}
+		assetJSON, err := json.Marshal(asset)
+		require.NoError(t, err)
+		timestamp, err := ptypes.TimestampProto(start.Add(time.Duration(offset) * time.Hour))
+		require.NoError(t, err)
+		history = append(history, &queryresult.KeyModification{TxId: string(rune('a' + offset)), Value: assetJSON, Timestamp: timestamp})
+	}
+	stub.history["asset1"] = history
+
+	records, err := chaincode.getChronologicalHistory(ctx, "asset1")
+	require.NoError(t, err)
+	var txIDs []string
+	for _, record := range records {
+		txIDs = append(txIDs, record.TxId)
+	}
+	require.Equal(t, []string{"c", "a", "b"}, txIDs)
+}
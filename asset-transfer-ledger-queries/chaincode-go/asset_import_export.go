@@ -0,0 +1,289 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// indexDocType is the docType of the index entry lines of an export
+const indexDocType = "index"
+
+// IndexEntry is an index entry line of an export. Attributes are the attributes of
+// the composite key, e.g. the color and the ID of the asset for the color~name index.
+type IndexEntry struct {
+	DocType    string   `json:"docType"`
+	Index      string   `json:"index"`
+	Attributes []string `json:"attributes"`
+}
+
+// ExportResult structure used for returning a page of exported assets as JSON lines
+type ExportResult struct {
+	Lines               string `json:"lines"`
+	FetchedRecordsCount int32  `json:"fetchedRecordsCount"`
+	Bookmark            string `json:"bookmark"`
+}
+
+// ImportResult structure used for returning the outcome of an import
+type ImportResult struct {
+	ImportedCount int           `json:"importedCount"`
+	Errors        []ImportError `json:"errors"`
+}
+
+// ImportError is the reason a line of an import was not imported. Line numbers start at 1.
+type ImportError struct {
+	Line  int    `json:"line"`
+	ID    string `json:"ID"`
+	Error string `json:"error"`
+}
+
+// ExportAssets returns a page of the assets in a key range as JSON lines, starting after the
+// bookmark. Each asset is exported as stored, followed by its index entries.
+// The page can be imported on another channel with ImportAssets.
+// Paginated range queries are only valid for read only transactions.
+// Example: Pagination with Range Query
+func (t *SimpleChaincode) ExportAssets(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int, bookmark string) (*ExportResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be a positive integer")
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var lines bytes.Buffer
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var asset Asset
+		err = json.Unmarshal(queryResult.Value, &asset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %v", queryResult.Key, err)
+		}
+		if asset.DocType != "asset" {
+			continue
+		}
+
+		// JSON lines hold one document per line
+		err = json.Compact(&lines, queryResult.Value)
+		if err != nil {
+			return nil, err
+		}
+		lines.WriteByte('\n')
+
+		indexEntries, err := assetIndexEntries(ctx, &asset)
+		if err != nil {
+			return nil, err
+		}
+		for _, indexEntry := range indexEntries {
+			indexEntryBytes, err := json.Marshal(indexEntry)
+			if err != nil {
+				return nil, err
+			}
+			lines.Write(indexEntryBytes)
+			lines.WriteByte('\n')
+		}
+	}
+
+	return &ExportResult{
+		Lines:               lines.String(),
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// ImportAssets creates the assets of JSON lines, as returned by ExportAssets.
+// Each asset line is validated and imported on its own: lines that cannot be imported are
+// reported in the result and do not prevent the other assets from being imported.
+// Index entry lines are checked against the imported assets, the index entries themselves
+// are rebuilt from the assets.
+func (t *SimpleChaincode) ImportAssets(ctx contractapi.TransactionContextInterface, assets string) (*ImportResult, error) {
+	result := &ImportResult{Errors: []ImportError{}}
+	imported := map[string]*Asset{}
+	var indexLines []int
+	var indexEntries []*IndexEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(assets))
+	// Allow long lines, the default limit of the scanner is 64KB
+	scanner.Buffer(nil, len(assets)+1)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var document struct {
+			DocType string `json:"docType"`
+			ID      string `json:"ID"`
+		}
+		err := json.Unmarshal(line, &document)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: lineNumber, Error: fmt.Sprintf("invalid JSON: %v", err)})
+			continue
+		}
+
+		if document.DocType == indexDocType {
+			var indexEntry IndexEntry
+			err = unmarshalStrict(line, &indexEntry)
+			if err != nil {
+				result.Errors = append(result.Errors, ImportError{Line: lineNumber, Error: err.Error()})
+				continue
+			}
+			indexLines = append(indexLines, lineNumber)
+			indexEntries = append(indexEntries, &indexEntry)
+			continue
+		}
+
+		asset, err := t.importAsset(ctx, line, imported)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: lineNumber, ID: document.ID, Error: err.Error()})
+			continue
+		}
+		imported[asset.ID] = asset
+		result.ImportedCount++
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read line %d: %v", lineNumber+1, err)
+	}
+
+	for i, indexEntry := range indexEntries {
+		err = checkIndexEntry(ctx, indexEntry, imported)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: indexLines[i], Error: err.Error()})
+		}
+	}
+	sort.Slice(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+
+	return result, nil
+}
+
+// importAsset validates an asset line and saves the asset with its index entries
+func (t *SimpleChaincode) importAsset(ctx contractapi.TransactionContextInterface, line []byte, imported map[string]*Asset) (*Asset, error) {
+	err := unmarshalStrict(line, &Asset{})
+	if err != nil {
+		return nil, err
+	}
+	// Assets exported with an older schema version are upgraded
+	asset, err := unmarshalAsset(line)
+	if err != nil {
+		return nil, err
+	}
+	if asset.DocType != "asset" {
+		return nil, fmt.Errorf("unknown docType %q, expected asset or %s", asset.DocType, indexDocType)
+	}
+	if asset.ID == "" {
+		return nil, fmt.Errorf("ID must be a non-empty string")
+	}
+	if strings.HasPrefix(asset.ID, "\x00") {
+		return nil, fmt.Errorf("ID must not start with a null character")
+	}
+	if imported[asset.ID] != nil {
+		return nil, fmt.Errorf("asset %s appears more than once", asset.ID)
+	}
+
+	exists, err := t.AssetExists(ctx, asset.ID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("asset already exists: %s", asset.ID)
+	}
+
+	// Validate the asset and build its index keys before writing anything, so that a line
+	// that fails is not left half imported
+	assetBytes, err := marshalAsset(asset)
+	if err != nil {
+		return nil, err
+	}
+	indexKeys, err := assetIndexKeys(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().PutState(asset.ID, assetBytes)
+	if err != nil {
+		return nil, err
+	}
+	value := []byte{0x00}
+	for _, indexKey := range indexKeys {
+		err = ctx.GetStub().PutState(indexKey, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return asset, nil
+}
+
+// assetIndexEntries returns the index entry lines of an asset
+func assetIndexEntries(ctx contractapi.TransactionContextInterface, asset *Asset) ([]*IndexEntry, error) {
+	indexKeys, err := assetIndexKeys(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+
+	var indexEntries []*IndexEntry
+	for _, indexKey := range indexKeys {
+		indexName, attributes, err := ctx.GetStub().SplitCompositeKey(indexKey)
+		if err != nil {
+			return nil, err
+		}
+		indexEntries = append(indexEntries, &IndexEntry{DocType: indexDocType, Index: indexName, Attributes: attributes})
+	}
+
+	return indexEntries, nil
+}
+
+// checkIndexEntry verifies that an index entry line matches an index entry of an imported asset
+func checkIndexEntry(ctx contractapi.TransactionContextInterface, indexEntry *IndexEntry, imported map[string]*Asset) error {
+	if len(indexEntry.Attributes) == 0 {
+		return fmt.Errorf("index entry of %s has no attributes", indexEntry.Index)
+	}
+	assetID := indexEntry.Attributes[len(indexEntry.Attributes)-1]
+	asset := imported[assetID]
+	if asset == nil {
+		return fmt.Errorf("index entry of %s refers to asset %s, which was not imported", indexEntry.Index, assetID)
+	}
+
+	expectedEntries, err := assetIndexEntries(ctx, asset)
+	if err != nil {
+		return err
+	}
+	for _, expected := range expectedEntries {
+		if expected.Index == indexEntry.Index && strings.Join(expected.Attributes, "\x00") == strings.Join(indexEntry.Attributes, "\x00") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("index entry of %s %v does not match asset %s", indexEntry.Index, indexEntry.Attributes, assetID)
+}
+
+// unmarshalStrict unmarshals a JSON document, rejecting fields that are not part of v
+func unmarshalStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err != nil {
+		return fmt.Errorf("invalid document: %v", err)
+	}
+	return nil
+}
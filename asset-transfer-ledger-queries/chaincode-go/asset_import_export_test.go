@@ -0,0 +1,144 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	_, source := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	require.NoError(t, chaincode.CreateAsset(source, "asset1", "blue", 5, "tom", 35))
+	require.NoError(t, chaincode.CreateAsset(source, "asset2", "red", 4, "jerry", 50))
+	require.NoError(t, chaincode.CreateAsset(source, "asset3", "blue", 6, "tom", 70))
+
+	var lines strings.Builder
+	bookmark := ""
+	pages := 0
+	for {
+		result, err := chaincode.ExportAssets(source, "", "", 2, bookmark)
+		require.NoError(t, err)
+		lines.WriteString(result.Lines)
+		pages++
+		if result.Bookmark == "" {
+			break
+		}
+		bookmark = result.Bookmark
+	}
+	require.Equal(t, 2, pages)
+	// Each asset is followed by its color, owner and size index entries
+	require.Equal(t, 12, strings.Count(lines.String(), "\n"))
+
+	_, destination := newLedgerStub()
+	result, err := chaincode.ImportAssets(destination, lines.String())
+	require.NoError(t, err)
+	require.Equal(t, &ImportResult{ImportedCount: 3, Errors: []ImportError{}}, result)
+
+	expected, err := chaincode.GetAssetsByRange(source, "", "")
+	require.NoError(t, err)
+	actual, err := chaincode.GetAssetsByRange(destination, "", "")
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	// The index entries are rebuilt
+	assets, err := chaincode.GetAssetsByColor(destination, "blue")
+	require.NoError(t, err)
+	require.Len(t, assets, 2)
+	assets, err = chaincode.GetAssetsByOwner(destination, "jerry")
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+	assets, err = chaincode.GetAssetsBySizeRange(destination, 6, 6)
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+}
+
+func TestImportAssetsReportsInvalidLines(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset1", "blue", 5, "tom", 35))
+
+	lines := strings.Join([]string{
+		`{"docType":"asset","ID":"asset1","color":"blue","size":5,"owner":"tom","appraisedValue":35,"schemaVersion":2}`,
+		`{"docType":"asset","ID":"asset2","color":"red","size":4,"owner":"jerry","appraisedValue":50,"price":10}`,
+		`{"docType":"asset","ID":"asset3","color":"red","size":4,"owner":"jerry","appraisedValue":50}`,
+		`{"docType":"asset","ID":"asset3","color":"red","size":4,"owner":"jerry","appraisedValue":50}`,
+		`{"docType":"index","index":"owner~name","attributes":["tom","asset3"]}`,
+		`{"docType":"index","index":"owner~name","attributes":["jerry","asset3"],"key":"x"}`,
+		`{"docType":"index","index":"color~name","attributes":["red","asset3"]}`,
+		`{"docType":"marble","ID":"marble1"}`,
+		`not json`,
+		``,
+	}, "\n")
+
+	result, err := chaincode.ImportAssets(ctx, lines)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.ImportedCount)
+	require.Equal(t, []ImportError{
+		{Line: 1, ID: "asset1", Error: "asset already exists: asset1"},
+		{Line: 2, ID: "asset2", Error: `invalid document: json: unknown field "price"`},
+		{Line: 4, ID: "asset3", Error: "asset asset3 appears more than once"},
+		{Line: 5, Error: "index entry of owner~name [tom asset3] does not match asset asset3"},
+		{Line: 6, Error: `invalid document: json: unknown field "key"`},
+		{Line: 8, ID: "marble1", Error: `unknown docType "marble", expected asset or index`},
+		{Line: 9, Error: "invalid JSON: invalid character 'o' in literal null (expecting 'u')"},
+	}, result.Errors)
+
+	// asset3 was exported with schema version 1 and is upgraded on import
+	asset, err := chaincode.ReadAsset(ctx, "asset3")
+	require.NoError(t, err)
+	require.Equal(t, currentSchemaVersion, asset.SchemaVersion)
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	tests := []struct {
+		document string
+		err      string
+	}{
+		{document: `{"docType":"index","index":"color~name","attributes":["blue","asset1"]}`},
+		{document: `{"docType":"index"}`},
+		{
+			document: `{"docType":"index","index":"color~name","attributes":["blue","asset1"],"owner":"tom"}`,
+			err:      `invalid document: json: unknown field "owner"`,
+		},
+		{
+			document: `{"docType":"index","attributes":"blue"}`,
+			err:      "invalid document: json: cannot unmarshal string into Go struct field IndexEntry.attributes of type []string",
+		},
+		{
+			document: `{"docType":`,
+			err:      "invalid document: unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.document, func(t *testing.T) {
+			var indexEntry IndexEntry
+			err := unmarshalStrict([]byte(test.document), &indexEntry)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "index", indexEntry.DocType)
+		})
+	}
+}
+
+func TestImportAssetsWritesNothingForInvalidIndexKeys(t *testing.T) {
+	stub, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+
+	// U+10FFFF is valid JSON but cannot be part of a composite key
+	line := `{"docType":"asset","ID":"asset1","color":"\udbff\udfff","size":5,"owner":"tom","appraisedValue":35}`
+	result, err := chaincode.ImportAssets(ctx, line)
+	require.NoError(t, err)
+	require.Equal(t, 0, result.ImportedCount)
+	require.Len(t, result.Errors, 1)
+	require.Empty(t, stub.State)
+}
@@ -0,0 +1,258 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Secondary indexes kept next to the color~name index, so that owner, color and size
+// queries can be answered with composite key range queries on any state database.
+const (
+	ownerIndex = "owner~name"
+	sizeIndex  = "size~name"
+)
+
+// assetIndexKeys returns the composite keys of all the index entries of an asset
+func assetIndexKeys(ctx contractapi.TransactionContextInterface, asset *Asset) ([]string, error) {
+	colorNameIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{asset.Color, asset.ID})
+	if err != nil {
+		return nil, err
+	}
+	ownerNameIndexKey, err := ctx.GetStub().CreateCompositeKey(ownerIndex, []string{asset.Owner, asset.ID})
+	if err != nil {
+		return nil, err
+	}
+	sizeNameIndexKey, err := ctx.GetStub().CreateCompositeKey(sizeIndex, []string{encodeSize(asset.Size), asset.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{colorNameIndexKey, ownerNameIndexKey, sizeNameIndexKey}, nil
+}
+
+// putAssetIndexes saves the index entries of an asset to world state.
+// Only the key name is needed, no need to store a duplicate copy of the asset.
+// Note - passing a 'nil' value will effectively delete the key from state, therefore we pass null character as value
+func putAssetIndexes(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	indexKeys, err := assetIndexKeys(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	value := []byte{0x00}
+	for _, indexKey := range indexKeys {
+		err = ctx.GetStub().PutState(indexKey, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteAssetIndexes removes the index entries of an asset from world state
+func deleteAssetIndexes(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	indexKeys, err := assetIndexKeys(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	for _, indexKey := range indexKeys {
+		err = ctx.GetStub().DelState(indexKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeSize encodes a size as a fixed width string, so that index entries sort by size.
+// Flipping the sign bit maps negative sizes below positive ones.
+func encodeSize(size int) string {
+	return fmt.Sprintf("%020d", uint64(int64(size))^(1<<63))
+}
+
+// decodeSize reverses encodeSize
+func decodeSize(encoded string) (int, error) {
+	value, err := strconv.ParseUint(encoded, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int(int64(value ^ (1 << 63))), nil
+}
+
+// BuildAssetIndexes writes the owner~name and size~name index entries of the assets in the
+// given key range. It is needed once for assets created before those indexes were maintained.
+func (t *SimpleChaincode) BuildAssetIndexes(ctx contractapi.TransactionContextInterface, startKey, endKey string) (int, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	assets, err := constructQueryResponseFromIterator(resultsIterator)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, asset := range assets {
+		err = putAssetIndexes(ctx, asset)
+		if err != nil {
+			return 0, fmt.Errorf("failed to index asset %s: %v", asset.ID, err)
+		}
+	}
+
+	return len(assets), nil
+}
+
+// GetAssetsByOwner returns the assets of an owner using the owner~name index.
+// Unlike QueryAssetsByOwner, it does not need a state database that supports rich query.
+// Example: GetStateByPartialCompositeKey
+func (t *SimpleChaincode) GetAssetsByOwner(ctx contractapi.TransactionContextInterface, owner string) ([]*Asset, error) {
+	return t.getAssetsByIndex(ctx, ownerIndex, owner)
+}
+
+// GetAssetsByColor returns the assets of a color using the color~name index.
+// Example: GetStateByPartialCompositeKey
+func (t *SimpleChaincode) GetAssetsByColor(ctx contractapi.TransactionContextInterface, color string) ([]*Asset, error) {
+	return t.getAssetsByIndex(ctx, index, color)
+}
+
+// GetAssetsByOwnerWithPagination returns a page of the assets of an owner using the owner~name index,
+// page size and a bookmark. It can be used instead of QueryAssetsWithPagination on any state database.
+// Paginated queries are only valid for read only transactions.
+// Example: GetStateByPartialCompositeKeyWithPagination
+func (t *SimpleChaincode) GetAssetsByOwnerWithPagination(ctx contractapi.TransactionContextInterface, owner string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(ownerIndex, []string{owner}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	assets, err := t.constructAssetsFromIndexIterator(ctx, resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedQueryResult{
+		Records:             assets,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+		Bookmark:            responseMetadata.Bookmark,
+	}, nil
+}
+
+// GetAssetsBySizeRange returns the assets with a size between minSize and maxSize (inclusive)
+// using the size~name index. Composite keys can only be range queried by prefix, so the index
+// is read in size order from the start, up to the first entry past maxSize.
+func (t *SimpleChaincode) GetAssetsBySizeRange(ctx contractapi.TransactionContextInterface, minSize int, maxSize int) ([]*Asset, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(sizeIndex, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var assets []*Asset
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+
+		size, err := decodeSize(compositeKeyParts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid size index entry %s: %v", responseRange.Key, err)
+		}
+		if size < minSize {
+			continue
+		}
+		if size > maxSize {
+			break
+		}
+
+		asset, err := t.ReadAsset(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// getAssetsByIndex returns the assets of the index entries matching a value
+func (t *SimpleChaincode) getAssetsByIndex(ctx contractapi.TransactionContextInterface, indexName, value string) ([]*Asset, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(indexName, []string{value})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return t.constructAssetsFromIndexIterator(ctx, resultsIterator)
+}
+
+// constructAssetsFromIndexIterator reads the asset of each index entry from the resultsIterator
+func (t *SimpleChaincode) constructAssetsFromIndexIterator(ctx contractapi.TransactionContextInterface, resultsIterator shim.StateQueryIteratorInterface) ([]*Asset, error) {
+	var assets []*Asset
+	for resultsIterator.HasNext() {
+		responseRange, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, compositeKeyParts, err := ctx.GetStub().SplitCompositeKey(responseRange.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(compositeKeyParts) < 2 {
+			continue
+		}
+
+		asset, err := t.ReadAsset(ctx, compositeKeyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// transferAssetOwner sets a new owner on the asset and moves its owner~name index entry
+func transferAssetOwner(ctx contractapi.TransactionContextInterface, asset *Asset, newOwner string) error {
+	oldOwnerIndexKey, err := ctx.GetStub().CreateCompositeKey(ownerIndex, []string{asset.Owner, asset.ID})
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(oldOwnerIndexKey)
+	if err != nil {
+		return err
+	}
+
+	asset.Owner = newOwner
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+
+	newOwnerIndexKey, err := ctx.GetStub().CreateCompositeKey(ownerIndex, []string{newOwner, asset.ID})
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(newOwnerIndexKey, []byte{0x00})
+}
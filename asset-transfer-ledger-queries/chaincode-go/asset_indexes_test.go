@@ -0,0 +1,60 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeSize(t *testing.T) {
+	sizes := []int{math.MinInt64, -1000, -10, -2, -1, 0, 1, 2, 9, 10, 11, 100, 1000, math.MaxInt64}
+
+	var encoded []string
+	for _, size := range sizes {
+		encodedSize := encodeSize(size)
+		require.Len(t, encodedSize, 20, "size %d", size)
+
+		decodedSize, err := decodeSize(encodedSize)
+		require.NoError(t, err)
+		require.Equal(t, size, decodedSize)
+
+		encoded = append(encoded, encodedSize)
+	}
+
+	// The sizes are in ascending order, so must be their encodings
+	require.True(t, sort.StringsAreSorted(encoded), "encoded sizes are not sorted: %v", encoded)
+}
+
+func TestDecodeSize(t *testing.T) {
+	_, err := decodeSize("size")
+	require.EqualError(t, err, `strconv.ParseUint: parsing "size": invalid syntax`)
+
+	_, err = decodeSize("-9223372036854775808")
+	require.Error(t, err)
+}
+
+func TestGetAssetsBySizeRange(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	for id, size := range map[string]int{"asset1": 10, "asset2": 2, "asset3": 9, "asset4": 100, "asset5": 0} {
+		require.NoError(t, chaincode.CreateAsset(ctx, id, "blue", size, "tom", 10))
+	}
+
+	assets, err := chaincode.GetAssetsBySizeRange(ctx, 2, 10)
+	require.NoError(t, err)
+	var ids []string
+	for _, asset := range assets {
+		ids = append(ids, asset.ID)
+	}
+	require.Equal(t, []string{"asset2", "asset3", "asset1"}, ids)
+
+	assets, err = chaincode.GetAssetsBySizeRange(ctx, -5, -1)
+	require.NoError(t, err)
+	require.Empty(t, assets)
+}
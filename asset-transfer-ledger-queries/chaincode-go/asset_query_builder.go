@@ -0,0 +1,233 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AssetQuery is a typed query request for assets, compiled to a CouchDB selector by SearchAssets.
+// Example:
+//
+//	{"filters":[{"field":"owner","op":"eq","value":"tom"},{"field":"size","op":"gt","value":3}],
+//	 "sort":[{"field":"size","order":"desc"}],"fields":["ID","size"],"limit":10}
+type AssetQuery struct {
+	Filters []QueryFilter `json:"filters"`
+	Sort    []QuerySort   `json:"sort"`
+	Fields  []string      `json:"fields"`
+	Limit   int           `json:"limit"`
+}
+
+// QueryFilter restricts an asset field. Op is one of eq, ne, gt, lt or in.
+// The value of an in filter is an array.
+type QueryFilter struct {
+	Field string          `json:"field"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value"`
+}
+
+// QuerySort orders the results on an asset field. Order is asc (default) or desc.
+type QuerySort struct {
+	Field string `json:"field"`
+	Order string `json:"order"`
+}
+
+// queryField describes an asset field that can be used in an AssetQuery
+type queryField struct {
+	numeric   bool
+	sortIndex string // design doc of the index used to sort on the field, empty if the field cannot be sorted on
+}
+
+// queryFields is the allow-list of asset fields that can be filtered on.
+// Each of them is covered by an index packaged in META-INF/statedb/couchdb/indexes.
+var queryFields = map[string]queryField{
+	"docType":        {},
+	"owner":          {sortIndex: "indexOwnerSortDoc"},
+	"color":          {sortIndex: "indexColorDoc"},
+	"size":           {numeric: true, sortIndex: "indexSizeDoc"},
+	"appraisedValue": {numeric: true, sortIndex: "indexAppraisedValueDoc"},
+}
+
+// projectionFields are the asset fields that can be returned by a query
+var projectionFields = map[string]bool{
+	"docType":        true,
+	"ID":             true,
+	"color":          true,
+	"size":           true,
+	"owner":          true,
+	"appraisedValue": true,
+	"schemaVersion":  true,
+}
+
+var queryOperators = map[string]string{
+	"eq": "$eq",
+	"ne": "$ne",
+	"gt": "$gt",
+	"lt": "$lt",
+	"in": "$in",
+}
+
+// SearchAssets performs a query for assets described by a typed query request, instead of a raw
+// query string. The request is validated against the indexed asset fields and compiled to a selector.
+// Only available on state databases that support rich query (e.g. CouchDB)
+// Example: Typed rich query
+func (t *SimpleChaincode) SearchAssets(ctx contractapi.TransactionContextInterface, queryRequest string) ([]*Asset, error) {
+	var query AssetQuery
+	err := json.Unmarshal([]byte(queryRequest), &query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal query request: %v", err)
+	}
+
+	queryString, err := compileAssetQuery(&query)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	// The peer applies its own limit to rich queries, so the query limit is applied here
+	var assets []*Asset
+	for resultsIterator.HasNext() {
+		if query.Limit > 0 && len(assets) == query.Limit {
+			break
+		}
+
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		asset, err := unmarshalAsset(queryResult.Value)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, asset)
+	}
+
+	return assets, nil
+}
+
+// compileAssetQuery validates the query request and builds the matching CouchDB query string
+func compileAssetQuery(query *AssetQuery) (string, error) {
+	if query.Limit < 0 {
+		return "", fmt.Errorf("limit must not be negative")
+	}
+
+	selector := map[string]interface{}{}
+	for _, filter := range query.Filters {
+		field, ok := queryFields[filter.Field]
+		if !ok {
+			return "", fmt.Errorf("cannot filter on field %q, filters are allowed on: %s", filter.Field, queryFieldNames())
+		}
+		operator, ok := queryOperators[filter.Op]
+		if !ok {
+			return "", fmt.Errorf("unknown operator %q on field %s, use one of eq, ne, gt, lt or in", filter.Op, filter.Field)
+		}
+
+		value, err := filterValue(filter, field, filter.Op == "in")
+		if err != nil {
+			return "", err
+		}
+
+		conditions, ok := selector[filter.Field].(map[string]interface{})
+		if !ok {
+			conditions = map[string]interface{}{}
+			selector[filter.Field] = conditions
+		}
+		if _, exists := conditions[operator]; exists {
+			return "", fmt.Errorf("duplicate %s filter on field %s", filter.Op, filter.Field)
+		}
+		conditions[operator] = value
+	}
+
+	// Only return assets, unless the request asks for another document type
+	if _, ok := selector["docType"]; !ok {
+		selector["docType"] = "asset"
+	}
+
+	couchQuery := map[string]interface{}{"selector": selector}
+
+	if len(query.Sort) > 1 {
+		return "", fmt.Errorf("sorting on more than one field is not supported")
+	}
+	for _, querySort := range query.Sort {
+		field, ok := queryFields[querySort.Field]
+		if !ok || field.sortIndex == "" {
+			return "", fmt.Errorf("cannot sort on field %q: no index is defined for it", querySort.Field)
+		}
+
+		order := querySort.Order
+		if order == "" {
+			order = "asc"
+		}
+		if order != "asc" && order != "desc" {
+			return "", fmt.Errorf("unknown sort order %q on field %s, use asc or desc", querySort.Order, querySort.Field)
+		}
+
+		// CouchDB only uses an index for sorting when the selector refers to the indexed field
+		if _, ok := selector[querySort.Field]; !ok {
+			selector[querySort.Field] = map[string]interface{}{"$gt": nil}
+		}
+		couchQuery["sort"] = []map[string]string{{querySort.Field: order}}
+		couchQuery["use_index"] = "_design/" + field.sortIndex
+	}
+
+	if len(query.Fields) > 0 {
+		for _, name := range query.Fields {
+			if !projectionFields[name] {
+				return "", fmt.Errorf("unknown field %q in projection", name)
+			}
+		}
+		couchQuery["fields"] = query.Fields
+	}
+
+	queryBytes, err := json.Marshal(couchQuery)
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryBytes), nil
+}
+
+// filterValue checks that the filter value matches the type of the field
+func filterValue(filter QueryFilter, field queryField, isList bool) (interface{}, error) {
+	var value interface{}
+	var expected string
+	switch {
+	case field.numeric && isList:
+		value, expected = &[]int{}, "an array of integers"
+	case field.numeric:
+		value, expected = new(int), "an integer"
+	case isList:
+		value, expected = &[]string{}, "an array of strings"
+	default:
+		value, expected = new(string), "a string"
+	}
+
+	err := json.Unmarshal(filter.Value, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %s for field %s, expected %s", filter.Value, filter.Field, expected)
+	}
+
+	return value, nil
+}
+
+// queryFieldNames lists the fields of the allow-list in a stable order
+func queryFieldNames() string {
+	var names []string
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,173 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileAssetQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+		err      string
+	}{
+		{
+			name:     "no filter returns all assets",
+			query:    `{}`,
+			expected: `{"selector":{"docType":"asset"}}`,
+		},
+		{
+			name:     "equality and range filters",
+			query:    `{"filters":[{"field":"owner","op":"eq","value":"tom"},{"field":"size","op":"gt","value":3},{"field":"size","op":"lt","value":9}]}`,
+			expected: `{"selector":{"docType":"asset","owner":{"$eq":"tom"},"size":{"$gt":3,"$lt":9}}}`,
+		},
+		{
+			name:     "in filter",
+			query:    `{"filters":[{"field":"color","op":"in","value":["blue","red"]}]}`,
+			expected: `{"selector":{"color":{"$in":["blue","red"]},"docType":"asset"}}`,
+		},
+		{
+			name:     "docType filter replaces the default",
+			query:    `{"filters":[{"field":"docType","op":"ne","value":"asset"}]}`,
+			expected: `{"selector":{"docType":{"$ne":"asset"}}}`,
+		},
+		{
+			name:     "sort adds the sorted field to the selector and uses its index",
+			query:    `{"sort":[{"field":"size","order":"desc"}],"fields":["ID","size"]}`,
+			expected: `{"fields":["ID","size"],"selector":{"docType":"asset","size":{"$gt":null}},"sort":[{"size":"desc"}],"use_index":"_design/indexSizeDoc"}`,
+		},
+		{
+			name:     "sort order defaults to asc",
+			query:    `{"filters":[{"field":"owner","op":"eq","value":"tom"}],"sort":[{"field":"owner"}]}`,
+			expected: `{"selector":{"docType":"asset","owner":{"$eq":"tom"}},"sort":[{"owner":"asc"}],"use_index":"_design/indexOwnerSortDoc"}`,
+		},
+		{
+			name:  "negative limit",
+			query: `{"limit":-1}`,
+			err:   "limit must not be negative",
+		},
+		{
+			name:  "field not in the allow-list",
+			query: `{"filters":[{"field":"ID","op":"eq","value":"asset1"}]}`,
+			err:   `cannot filter on field "ID", filters are allowed on: appraisedValue, color, docType, owner, size`,
+		},
+		{
+			name:  "unknown operator",
+			query: `{"filters":[{"field":"size","op":"gte","value":1}]}`,
+			err:   `unknown operator "gte" on field size, use one of eq, ne, gt, lt or in`,
+		},
+		{
+			name:  "duplicate filter",
+			query: `{"filters":[{"field":"size","op":"gt","value":1},{"field":"size","op":"gt","value":2}]}`,
+			err:   "duplicate gt filter on field size",
+		},
+		{
+			name:  "sort on more than one field",
+			query: `{"sort":[{"field":"size"},{"field":"owner"}]}`,
+			err:   "sorting on more than one field is not supported",
+		},
+		{
+			name:  "sort on a field without index",
+			query: `{"sort":[{"field":"docType"}]}`,
+			err:   `cannot sort on field "docType": no index is defined for it`,
+		},
+		{
+			name:  "unknown sort order",
+			query: `{"sort":[{"field":"size","order":"up"}]}`,
+			err:   `unknown sort order "up" on field size, use asc or desc`,
+		},
+		{
+			name:  "unknown projection field",
+			query: `{"fields":["price"]}`,
+			err:   `unknown field "price" in projection`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var query AssetQuery
+			require.NoError(t, json.Unmarshal([]byte(test.query), &query))
+
+			queryString, err := compileAssetQuery(&query)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, queryString)
+		})
+	}
+}
+
+func TestFilterValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   QueryFilter
+		expected interface{}
+		err      string
+	}{
+		{
+			name:     "string",
+			filter:   QueryFilter{Field: "owner", Op: "eq", Value: json.RawMessage(`"tom"`)},
+			expected: "tom",
+		},
+		{
+			name:     "integer",
+			filter:   QueryFilter{Field: "size", Op: "gt", Value: json.RawMessage(`5`)},
+			expected: 5,
+		},
+		{
+			name:     "array of strings",
+			filter:   QueryFilter{Field: "color", Op: "in", Value: json.RawMessage(`["blue","red"]`)},
+			expected: []string{"blue", "red"},
+		},
+		{
+			name:     "array of integers",
+			filter:   QueryFilter{Field: "appraisedValue", Op: "in", Value: json.RawMessage(`[100,200]`)},
+			expected: []int{100, 200},
+		},
+		{
+			name:   "string for a numeric field",
+			filter: QueryFilter{Field: "size", Op: "eq", Value: json.RawMessage(`"5"`)},
+			err:    `invalid value "5" for field size, expected an integer`,
+		},
+		{
+			name:   "number for a string field",
+			filter: QueryFilter{Field: "owner", Op: "eq", Value: json.RawMessage(`5`)},
+			err:    "invalid value 5 for field owner, expected a string",
+		},
+		{
+			name:   "scalar for an in filter",
+			filter: QueryFilter{Field: "color", Op: "in", Value: json.RawMessage(`"blue"`)},
+			err:    `invalid value "blue" for field color, expected an array of strings`,
+		},
+		{
+			name:   "fraction for a numeric field",
+			filter: QueryFilter{Field: "size", Op: "in", Value: json.RawMessage(`[1.5]`)},
+			err:    "invalid value [1.5] for field size, expected an array of integers",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := filterValue(test.filter, queryFields[test.filter.Field], test.filter.Op == "in")
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			valueJSON, err := json.Marshal(value)
+			require.NoError(t, err)
+			expectedJSON, err := json.Marshal(test.expected)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expectedJSON), string(valueJSON))
+		})
+	}
+}
@@ -0,0 +1,221 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// currentSchemaVersion is the schemaVersion of the assets written by this chaincode.
+// Documents written before schemaVersion was introduced are version 1.
+const currentSchemaVersion = 2
+
+// assetSchema is the JSON Schema that every asset is validated against before it is written.
+// Its schemaVersion constant must be kept equal to currentSchemaVersion.
+const assetSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"$id": "asset.schema.json",
+	"title": "Asset",
+	"type": "object",
+	"properties": {
+		"docType": {"const": "asset"},
+		"ID": {"type": "string", "minLength": 1, "pattern": "^[^\\x00]"},
+		"color": {"type": "string", "minLength": 1},
+		"size": {"type": "integer", "minimum": 0},
+		"owner": {"type": "string", "minLength": 1},
+		"appraisedValue": {"type": "integer", "minimum": 0},
+		"schemaVersion": {"const": 2}
+	},
+	"required": ["docType", "ID", "color", "size", "owner", "appraisedValue", "schemaVersion"],
+	"additionalProperties": false
+}`
+
+var compiledAssetSchema = mustCompileSchema(assetSchema)
+
+// assetMigrations upgrade an asset document from the schema version they are keyed by to
+// the next one. When the schema changes, currentSchemaVersion is incremented and the
+// migration from the previous version is added here.
+var assetMigrations = map[int]func(document map[string]interface{}) error{
+	// Version 2 only adds the schemaVersion field
+	1: func(document map[string]interface{}) error {
+		return nil
+	},
+}
+
+// MigrationResult structure used for returning the outcome of a MigrateAssets batch
+type MigrationResult struct {
+	MigratedCount int    `json:"migratedCount"`
+	Bookmark      string `json:"bookmark"`
+}
+
+func mustCompileSchema(schema string) *gojsonschema.Schema {
+	compiled, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(schema))
+	if err != nil {
+		log.Panicf("Error compiling asset schema: %v", err)
+	}
+	return compiled
+}
+
+// GetAssetSchema returns the JSON Schema that assets are validated against
+func (t *SimpleChaincode) GetAssetSchema(ctx contractapi.TransactionContextInterface) (string, error) {
+	return assetSchema, nil
+}
+
+// MigrateAssets rewrites the assets stored with an older schema version with the current one,
+// at most maxRecords assets per transaction, in ID order after the bookmark.
+// The returned bookmark is the ID of the last migrated asset, to be passed to the next call
+// to continue; it is empty once all assets were checked.
+// Assets are also upgraded when they are read, so migrating them is only needed to keep rich
+// queries on the stored documents consistent.
+func (t *SimpleChaincode) MigrateAssets(ctx contractapi.TransactionContextInterface, maxRecords int, bookmark string) (*MigrationResult, error) {
+	if maxRecords <= 0 {
+		return nil, fmt.Errorf("maxRecords must be a positive integer")
+	}
+
+	startKey := ""
+	if bookmark != "" {
+		startKey = bookmark + "\x00"
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &MigrationResult{}
+	for resultsIterator.HasNext() {
+		if result.MigratedCount == maxRecords {
+			result.Bookmark = bookmark
+			break
+		}
+
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var document struct {
+			DocType       string `json:"docType"`
+			SchemaVersion int    `json:"schemaVersion"`
+		}
+		err = json.Unmarshal(queryResult.Value, &document)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %v", queryResult.Key, err)
+		}
+		if document.DocType != "asset" || document.SchemaVersion == currentSchemaVersion {
+			continue
+		}
+
+		asset, err := unmarshalAsset(queryResult.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate asset %s: %v", queryResult.Key, err)
+		}
+		err = putAsset(ctx, asset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate asset %s: %v", queryResult.Key, err)
+		}
+		result.MigratedCount++
+		bookmark = queryResult.Key
+	}
+
+	return result, nil
+}
+
+// putAsset validates an asset against the asset schema and saves it with the current schema version
+func putAsset(ctx contractapi.TransactionContextInterface, asset *Asset) error {
+	assetBytes, err := marshalAsset(asset)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(asset.ID, assetBytes)
+}
+
+// marshalAsset sets the current schema version on an asset and returns its document,
+// once validated against the asset schema
+func marshalAsset(asset *Asset) ([]byte, error) {
+	asset.SchemaVersion = currentSchemaVersion
+	assetBytes, err := json.Marshal(asset)
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateAssetDocument(assetBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset %s: %v", asset.ID, err)
+	}
+
+	return assetBytes, nil
+}
+
+// validateAssetDocument checks an asset document against the asset schema and reports all
+// the fields that do not match it
+func validateAssetDocument(document []byte) error {
+	result, err := compiledAssetSchema.Validate(gojsonschema.NewBytesLoader(document))
+	if err != nil {
+		return err
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	var fieldErrors []string
+	for _, resultError := range result.Errors() {
+		fieldErrors = append(fieldErrors, resultError.String())
+	}
+
+	return fmt.Errorf("%s", strings.Join(fieldErrors, "; "))
+}
+
+// unmarshalAsset unmarshals an asset document, upgrading it to the current schema version
+// if it was written with an older one
+func unmarshalAsset(assetBytes []byte) (*Asset, error) {
+	var document map[string]interface{}
+	err := json.Unmarshal(assetBytes, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if schemaVersion, ok := document["schemaVersion"].(float64); ok {
+		version = int(schemaVersion)
+	}
+	if version > currentSchemaVersion {
+		return nil, fmt.Errorf("schema version %d is newer than the supported version %d", version, currentSchemaVersion)
+	}
+
+	if version < currentSchemaVersion {
+		for ; version < currentSchemaVersion; version++ {
+			migrate, ok := assetMigrations[version]
+			if !ok {
+				return nil, fmt.Errorf("no migration from schema version %d", version)
+			}
+			err = migrate(document)
+			if err != nil {
+				return nil, fmt.Errorf("failed to migrate from schema version %d: %v", version, err)
+			}
+		}
+		document["schemaVersion"] = currentSchemaVersion
+
+		assetBytes, err = json.Marshal(document)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var asset Asset
+	err = json.Unmarshal(assetBytes, &asset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &asset, nil
+}
@@ -0,0 +1,106 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalAsset(t *testing.T) {
+	migrated := &Asset{DocType: "asset", ID: "asset1", Color: "blue", Size: 5, Owner: "tom", AppraisedValue: 35, SchemaVersion: currentSchemaVersion}
+
+	tests := []struct {
+		name     string
+		document string
+		expected *Asset
+		err      string
+	}{
+		{
+			name:     "version 1 without schemaVersion",
+			document: `{"docType":"asset","ID":"asset1","color":"blue","size":5,"owner":"tom","appraisedValue":35}`,
+			expected: migrated,
+		},
+		{
+			name:     "version 1",
+			document: `{"docType":"asset","ID":"asset1","color":"blue","size":5,"owner":"tom","appraisedValue":35,"schemaVersion":1}`,
+			expected: migrated,
+		},
+		{
+			name:     "current version",
+			document: `{"docType":"asset","ID":"asset1","color":"blue","size":5,"owner":"tom","appraisedValue":35,"schemaVersion":2}`,
+			expected: migrated,
+		},
+		{
+			name:     "future version",
+			document: `{"docType":"asset","ID":"asset1","color":"blue","size":5,"owner":"tom","appraisedValue":35,"schemaVersion":3}`,
+			err:      "schema version 3 is newer than the supported version 2",
+		},
+		{
+			name:     "version without migration",
+			document: `{"docType":"asset","ID":"asset1","schemaVersion":0}`,
+			err:      "no migration from schema version 0",
+		},
+		{
+			name:     "invalid JSON",
+			document: `{"docType":"asset"`,
+			err:      "unexpected end of JSON input",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			asset, err := unmarshalAsset([]byte(test.document))
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, asset)
+		})
+	}
+}
+
+func TestPutAssetValidatesSchema(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+
+	err := chaincode.CreateAsset(ctx, "asset1", "", -1, "tom", 35)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid asset asset1: ")
+	require.Contains(t, err.Error(), "color: String length must be greater than or equal to 1")
+	require.Contains(t, err.Error(), "size: Must be greater than or equal to 0")
+
+	exists, err := chaincode.AssetExists(ctx, "asset1")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestMigrateAssets(t *testing.T) {
+	stub, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	for i := 1; i <= 3; i++ {
+		document := fmt.Sprintf(`{"docType":"asset","ID":"asset%d","color":"blue","size":5,"owner":"tom","appraisedValue":35}`, i)
+		require.NoError(t, stub.PutState(fmt.Sprintf("asset%d", i), []byte(document)))
+	}
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset4", "red", 4, "jerry", 50))
+
+	result, err := chaincode.MigrateAssets(ctx, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, &MigrationResult{MigratedCount: 2, Bookmark: "asset2"}, result)
+
+	result, err = chaincode.MigrateAssets(ctx, 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, &MigrationResult{MigratedCount: 1}, result)
+
+	for i := 1; i <= 3; i++ {
+		require.Contains(t, string(stub.State[fmt.Sprintf("asset%d", i)]), `"schemaVersion":2`)
+	}
+
+	_, err = chaincode.MigrateAssets(ctx, 0, "")
+	require.EqualError(t, err, "maxRecords must be a positive integer")
+}
@@ -0,0 +1,122 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// isRichQueryUnsupported reports whether a query failed because the state database of the peer,
+// such as LevelDB, does not support rich queries
+func isRichQueryUnsupported(err error) bool {
+	return strings.Contains(err.Error(), "not supported for leveldb")
+}
+
+// equalitySelector is a rich query made of a selector only
+type equalitySelector struct {
+	Selector map[string]json.RawMessage `json:"selector"`
+}
+
+// parseEqualitySelector returns the field values required by a rich query whose selector only has
+// equality conditions on top level fields, such as {"selector":{"owner":"tom"}} or
+// {"selector":{"owner":{"$eq":"tom"}}}. Other queries need a state database that supports rich query.
+func parseEqualitySelector(queryString string) (map[string]interface{}, error) {
+	var query equalitySelector
+	err := unmarshalStrict([]byte(queryString), &query)
+	if err != nil {
+		return nil, fmt.Errorf("only a selector is supported without rich query support: %v", err)
+	}
+
+	conditions := make(map[string]interface{})
+	for field, condition := range query.Selector {
+		if len(field) > 0 && field[0] == '$' {
+			return nil, fmt.Errorf("operator %s is not supported without rich query support", field)
+		}
+
+		var value interface{}
+		err = json.Unmarshal(condition, &value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition on field %s: %v", field, err)
+		}
+		if operators, ok := value.(map[string]interface{}); ok {
+			eq, ok := operators["$eq"]
+			if !ok || len(operators) != 1 {
+				return nil, fmt.Errorf("only equality conditions are supported without rich query support, got %s on field %s", condition, field)
+			}
+			value = eq
+		}
+		conditions[field] = value
+	}
+
+	return conditions, nil
+}
+
+// getRangeQueryResultForSelectorWithPagination answers an equality selector with a range query on
+// all the assets, for state databases without rich query support. The bookmark is the key of the
+// last asset returned, and is empty once there are no more assets.
+func getRangeQueryResultForSelectorWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	conditions, err := parseEqualitySelector(queryString)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive")
+	}
+
+	startKey := ""
+	if bookmark != "" {
+		startKey = bookmark + "\x00"
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := &PaginatedQueryResult{}
+	for result.FetchedRecordsCount < pageSize && resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		asset, err := unmarshalAsset(queryResult.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %v", queryResult.Key, err)
+		}
+		// Match the asset in the current schema version, so that documents not yet migrated match too
+		assetJSON, err := json.Marshal(asset)
+		if err != nil {
+			return nil, err
+		}
+		var document map[string]interface{}
+		err = json.Unmarshal(assetJSON, &document)
+		if err != nil {
+			return nil, err
+		}
+
+		matches := true
+		for field, value := range conditions {
+			matches = matches && reflect.DeepEqual(document[field], value)
+		}
+		if !matches {
+			continue
+		}
+
+		result.Records = append(result.Records, asset)
+		result.FetchedRecordsCount++
+		result.Bookmark = queryResult.Key
+	}
+	if !resultsIterator.HasNext() {
+		result.Bookmark = ""
+	}
+
+	return result, nil
+}
@@ -0,0 +1,86 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryAssetsByOwnerOnLevelDB(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset1", "blue", 5, "tom", 35))
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset2", "red", 4, "jerry", 50))
+
+	// The stub does not support rich queries, so the owner index is used
+	assets, err := chaincode.QueryAssetsByOwner(ctx, "tom")
+	require.NoError(t, err)
+	require.Len(t, assets, 1)
+	require.Equal(t, "asset1", assets[0].ID)
+}
+
+func TestQueryAssetsWithPaginationOnLevelDB(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset1", "blue", 5, "tom", 35))
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset2", "red", 4, "jerry", 50))
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset3", "blue", 6, "tom", 70))
+	require.NoError(t, chaincode.CreateAsset(ctx, "asset4", "blue", 6, "tom", 70))
+
+	queryString := `{"selector":{"docType":"asset","owner":"tom","color":{"$eq":"blue"}}}`
+	result, err := chaincode.QueryAssetsWithPagination(ctx, queryString, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), result.FetchedRecordsCount)
+	require.Equal(t, "asset1", result.Records[0].ID)
+	require.Equal(t, "asset3", result.Records[1].ID)
+	require.Equal(t, "asset3", result.Bookmark)
+
+	result, err = chaincode.QueryAssetsWithPagination(ctx, queryString, 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), result.FetchedRecordsCount)
+	require.Equal(t, "asset4", result.Records[0].ID)
+	require.Equal(t, "", result.Bookmark)
+
+	result, err = chaincode.QueryAssetsWithPagination(ctx, `{"selector":{"size":6}}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), result.FetchedRecordsCount)
+
+	result, err = chaincode.QueryAssetsWithPagination(ctx, `{"selector":{"owner":"spike"}}`, 10, "")
+	require.NoError(t, err)
+	require.Equal(t, int32(0), result.FetchedRecordsCount)
+	require.Equal(t, "", result.Bookmark)
+}
+
+func TestQueryAssetsWithPaginationOnLevelDBRejectsRichQueries(t *testing.T) {
+	_, ctx := newLedgerStub()
+	chaincode := &SimpleChaincode{}
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{
+			query: `{"selector":{"size":{"$gt":4}}}`,
+			err:   `only equality conditions are supported without rich query support, got {"$gt":4} on field size`,
+		},
+		{
+			query: `{"selector":{"$or":[{"owner":"tom"},{"owner":"jerry"}]}}`,
+			err:   "operator $or is not supported without rich query support",
+		},
+		{
+			query: `{"selector":{"owner":"tom"},"sort":[{"size":"desc"}]}`,
+			err:   `only a selector is supported without rich query support: invalid document: json: unknown field "sort"`,
+		},
+	}
+	for _, test := range tests {
+		_, err := chaincode.QueryAssetsWithPagination(ctx, test.query, 10, "")
+		require.EqualError(t, err, test.err, test.query)
+	}
+
+	_, err := chaincode.QueryAssetsWithPagination(ctx, `{"selector":{"owner":"tom"}}`, 0, "")
+	require.EqualError(t, err, "page size must be positive")
+}
@@ -12,17 +12,38 @@ peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["CreateAsset","asse
 peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["TransferAsset","asset2","jerry"]}'
 peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["TransferAssetByColor","blue","jerry"]}'
 peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["DeleteAsset","asset1"]}'
+peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["MigrateAssets","100",""]}'
+peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["BulkUpdate","[{\"field\":\"size\",\"op\":\"gt\",\"value\":4}]","{\"owner\":\"jerry\"}","10",""]}'
+peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["ImportAssets","{\"docType\":\"asset\",\"ID\":\"asset4\",\"color\":\"red\",\"size\":7,\"owner\":\"tom\",\"appraisedValue\":60}\n"]}'
 
 ==== Query assets ====
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["ReadAsset","asset1"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetSchema"]}'
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetsByRange","asset1","asset3"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["ExportAssets","","","10",""]}'
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetHistory","asset1"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetHistoryDiff","asset1"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetAsOf","asset1","2021-01-02T15:04:05Z"]}'
 
-Rich Query (Only supported if CouchDB is used as state database):
+Index Query, using composite key indexes (Supported on LevelDB and CouchDB):
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetsByOwner","tom"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetsByColor","blue"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetsBySizeRange","4","6"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["GetAssetsByOwnerWithPagination","tom","3",""]}'
+peer chaincode invoke -C myc1 -n asset_transfer -c '{"Args":["BuildAssetIndexes","",""]}'
+
+Aggregate Query, paged through rich or range queries (Supported on LevelDB and CouchDB):
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["AggregateAssets","owner","sum(appraisedValue)"]}'
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["AggregateAssets","","count"]}'
+
+Rich Query (Only supported if CouchDB is used as state database, QueryAssetsByOwner uses the owner index on LevelDB):
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssetsByOwner","tom"]}'
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssets","{\"selector\":{\"owner\":\"tom\"}}"]}'
 
-Rich Query with Pagination (Only supported if CouchDB is used as state database):
+Typed Rich Query, compiled to a selector on indexed fields (Only supported if CouchDB is used as state database):
+peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["SearchAssets","{\"filters\":[{\"field\":\"owner\",\"op\":\"eq\",\"value\":\"tom\"}],\"sort\":[{\"field\":\"size\",\"order\":\"desc\"}],\"limit\":10}"]}'
+
+Rich Query with Pagination (Selectors with equality conditions only if LevelDB is used as state database):
 peer chaincode query -C myc1 -n asset_transfer -c '{"Args":["QueryAssetsWithPagination","{\"selector\":{\"owner\":\"tom\"}}","3",""]}'
 
 INDEXES TO SUPPORT COUCHDB RICH QUERIES
@@ -94,6 +115,7 @@ type Asset struct {
 	Size           int    `json:"size"`
 	Owner          string `json:"owner"`
 	AppraisedValue int    `json:"appraisedValue"`
+	SchemaVersion  int    `json:"schemaVersion"` //version of the asset schema the document was written with
 }
 
 // HistoryQueryResult structure used for returning result of history query
@@ -129,29 +151,17 @@ func (t *SimpleChaincode) CreateAsset(ctx contractapi.TransactionContextInterfac
 		Owner:          owner,
 		AppraisedValue: appraisedValue,
 	}
-	assetBytes, err := json.Marshal(asset)
-	if err != nil {
-		return err
-	}
-
-	err = ctx.GetStub().PutState(assetID, assetBytes)
+	err = putAsset(ctx, asset)
 	if err != nil {
 		return err
 	}
 
-	//  Create an index to enable color-based range queries, e.g. return all blue assets.
+	//  Create indexes to enable color, owner and size based range queries, e.g. return all blue assets.
 	//  An 'index' is a normal key-value entry in the ledger.
 	//  The key is a composite key, with the elements that you want to range query on listed first.
-	//  In our case, the composite key is based on indexName~color~name.
+	//  In our case, the composite keys are based on indexName~color~name, indexName~owner~name and indexName~size~name.
 	//  This will enable very efficient state range queries based on composite keys matching indexName~color~*
-	colorNameIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{asset.Color, asset.ID})
-	if err != nil {
-		return err
-	}
-	//  Save index entry to world state. Only the key name is needed, no need to store a duplicate copy of the asset.
-	//  Note - passing a 'nil' value will effectively delete the key from state, therefore we pass null character as value
-	value := []byte{0x00}
-	return ctx.GetStub().PutState(colorNameIndexKey, value)
+	return putAssetIndexes(ctx, asset)
 }
 
 // ReadAsset retrieves an asset from the ledger
@@ -164,13 +174,7 @@ func (t *SimpleChaincode) ReadAsset(ctx contractapi.TransactionContextInterface,
 		return nil, fmt.Errorf("asset %s does not exist", assetID)
 	}
 
-	var asset Asset
-	err = json.Unmarshal(assetBytes, &asset)
-	if err != nil {
-		return nil, err
-	}
-
-	return &asset, nil
+	return unmarshalAsset(assetBytes)
 }
 
 // DeleteAsset removes an asset key-value pair from the ledger
@@ -185,13 +189,8 @@ func (t *SimpleChaincode) DeleteAsset(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("failed to delete asset %s: %v", assetID, err)
 	}
 
-	colorNameIndexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{asset.Color, asset.ID})
-	if err != nil {
-		return err
-	}
-
-	// Delete index entry
-	return ctx.GetStub().DelState(colorNameIndexKey)
+	// Delete index entries
+	return deleteAssetIndexes(ctx, asset)
 }
 
 // TransferAsset transfers an asset by setting a new owner name on the asset
@@ -201,13 +200,7 @@ func (t *SimpleChaincode) TransferAsset(ctx contractapi.TransactionContextInterf
 		return err
 	}
 
-	asset.Owner = newOwner
-	assetBytes, err := json.Marshal(asset)
-	if err != nil {
-		return err
-	}
-
-	return ctx.GetStub().PutState(assetID, assetBytes)
+	return transferAssetOwner(ctx, asset, newOwner)
 }
 
 // constructQueryResponseFromIterator constructs a slice of assets from the resultsIterator
@@ -218,12 +211,11 @@ func constructQueryResponseFromIterator(resultsIterator shim.StateQueryIteratorI
 		if err != nil {
 			return nil, err
 		}
-		var asset Asset
-		err = json.Unmarshal(queryResult.Value, &asset)
+		asset, err := unmarshalAsset(queryResult.Value)
 		if err != nil {
 			return nil, err
 		}
-		assets = append(assets, &asset)
+		assets = append(assets, asset)
 	}
 
 	return assets, nil
@@ -253,6 +245,7 @@ func (t *SimpleChaincode) GetAssetsByRange(ctx contractapi.TransactionContextInt
 // between endorsement time and commit time. The transaction is invalidated by the
 // committing peers if the result set has changed between endorsement time and commit time.
 // Therefore, range queries are a safe option for performing update transactions based on query results.
+// All assets of the color are transferred in one transaction, use BulkUpdate to transfer them in batches.
 // Example: GetStateByPartialCompositeKey/RangeQuery
 func (t *SimpleChaincode) TransferAssetByColor(ctx contractapi.TransactionContextInterface, color, newOwner string) error {
 	// Execute a key range query on all keys starting with 'color'
@@ -279,12 +272,7 @@ func (t *SimpleChaincode) TransferAssetByColor(ctx contractapi.TransactionContex
 			if err != nil {
 				return err
 			}
-			asset.Owner = newOwner
-			assetBytes, err := json.Marshal(asset)
-			if err != nil {
-				return err
-			}
-			err = ctx.GetStub().PutState(returnedAssetID, assetBytes)
+			err = transferAssetOwner(ctx, asset, newOwner)
 			if err != nil {
 				return fmt.Errorf("transfer failed for asset %s: %v", returnedAssetID, err)
 			}
@@ -297,11 +285,15 @@ func (t *SimpleChaincode) TransferAssetByColor(ctx contractapi.TransactionContex
 // QueryAssetsByOwner queries for assets based on the owners name.
 // This is an example of a parameterized query where the query logic is baked into the chaincode,
 // and accepting a single query parameter (owner).
-// Only available on state databases that support rich query (e.g. CouchDB)
+// On state databases that do not support rich query (e.g. LevelDB), the owner~name index is used instead.
 // Example: Parameterized rich query
 func (t *SimpleChaincode) QueryAssetsByOwner(ctx contractapi.TransactionContextInterface, owner string) ([]*Asset, error) {
 	queryString := fmt.Sprintf(`{"selector":{"docType":"asset","owner":"%s"}}`, owner)
-	return getQueryResultForQueryString(ctx, queryString)
+	assets, err := getQueryResultForQueryString(ctx, queryString)
+	if err != nil && isRichQueryUnsupported(err) {
+		return t.GetAssetsByOwner(ctx, owner)
+	}
+	return assets, err
 }
 
 // QueryAssets uses a query string to perform a query for assets.
@@ -347,12 +339,16 @@ func (t *SimpleChaincode) GetAssetsByRangeWithPagination(ctx contractapi.Transac
 // The number of fetched records would be equal to or lesser than the specified page size.
 // Supports ad hoc queries that can be defined at runtime by the client.
 // If this is not desired, follow the QueryAssetsForOwner example for parameterized queries.
-// Only available on state databases that support rich query (e.g. CouchDB)
+// On state databases that do not support rich query (e.g. LevelDB), only selectors with equality
+// conditions are supported, and they are matched against a range query on all the assets.
 // Paginated queries are only valid for read only transactions.
 // Example: Pagination with Ad hoc Rich Query
 func (t *SimpleChaincode) QueryAssetsWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
-
-	return getQueryResultForQueryStringWithPagination(ctx, queryString, int32(pageSize), bookmark)
+	result, err := getQueryResultForQueryStringWithPagination(ctx, queryString, int32(pageSize), bookmark)
+	if err != nil && isRichQueryUnsupported(err) {
+		return getRangeQueryResultForSelectorWithPagination(ctx, queryString, int32(pageSize), bookmark)
+	}
+	return result, err
 }
 
 // getQueryResultForQueryStringWithPagination executes the passed in query string with
@@ -0,0 +1,194 @@
+/*
+ SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ledgerStub is a MockStub that answers range and history queries like a peer does:
+// range queries leave out composite keys, paginate, and do not see the writes of the
+// transaction, and history queries return the writes of a key newest first.
+// Rich queries are not supported, as on LevelDB. Events are recorded in order.
+type ledgerStub struct {
+	*shimtest.MockStub
+	history map[string][]*queryresult.KeyModification
+	events  []*peer.ChaincodeEvent
+}
+
+// newLedgerStub returns a ledgerStub and a transaction context using it, with a transaction started
+func newLedgerStub() (*ledgerStub, *contractapi.TransactionContext) {
+	stub := &ledgerStub{
+		MockStub: shimtest.NewMockStub("ledger", nil),
+		history:  make(map[string][]*queryresult.KeyModification),
+	}
+	stub.startTransaction("tx1", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(stub)
+	return stub, ctx
+}
+
+// startTransaction starts a new transaction with the given ID and timestamp
+func (s *ledgerStub) startTransaction(txID string, timestamp time.Time) {
+	s.MockTransactionStart(txID)
+	s.TxTimestamp, _ = ptypes.TimestampProto(timestamp)
+}
+
+func (s *ledgerStub) PutState(key string, value []byte) error {
+	err := s.MockStub.PutState(key, value)
+	if err != nil {
+		return err
+	}
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Value: value, Timestamp: s.TxTimestamp})
+	return nil
+}
+
+func (s *ledgerStub) DelState(key string) error {
+	err := s.MockStub.DelState(key)
+	if err != nil {
+		return err
+	}
+	s.history[key] = append(s.history[key], &queryresult.KeyModification{TxId: s.TxID, Timestamp: s.TxTimestamp, IsDelete: true})
+	return nil
+}
+
+// rangeKeys returns the simple keys from startKey (inclusive) to endKey (exclusive) in order,
+// an empty endKey meaning no end
+func (s *ledgerStub) rangeKeys(startKey, endKey string) []string {
+	var keys []string
+	for key := range s.State {
+		if strings.HasPrefix(key, "\x00") || key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *ledgerStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator := &stateIterator{}
+	for _, key := range s.rangeKeys(startKey, endKey) {
+		iterator.results = append(iterator.results, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	return iterator, nil
+}
+
+func (s *ledgerStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	keys := s.rangeKeys(startKey, endKey)
+	metadata := &peer.QueryResponseMetadata{}
+	if len(keys) > int(pageSize) {
+		metadata.Bookmark = keys[pageSize]
+		keys = keys[:pageSize]
+	}
+
+	iterator := &stateIterator{}
+	for _, key := range keys {
+		iterator.results = append(iterator.results, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	metadata.FetchedRecordsCount = int32(len(keys))
+	return iterator, metadata, nil
+}
+
+func (s *ledgerStub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := s.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key := range s.State {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	iterator := &stateIterator{}
+	for _, key := range keys {
+		iterator.results = append(iterator.results, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	return iterator, nil
+}
+
+func (s *ledgerStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	return nil, fmt.Errorf("ExecuteQuery not supported for leveldb")
+}
+
+func (s *ledgerStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return nil, nil, fmt.Errorf("ExecuteQuery not supported for leveldb")
+}
+
+func (s *ledgerStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	iterator := &historyIterator{}
+	for i := len(s.history[key]) - 1; i >= 0; i-- {
+		iterator.results = append(iterator.results, s.history[key][i])
+	}
+	return iterator, nil
+}
+
+func (s *ledgerStub) SetEvent(name string, payload []byte) error {
+	s.events = append(s.events, &peer.ChaincodeEvent{EventName: name, Payload: payload})
+	return nil
+}
+
+// stateIterator iterates over a slice of query results
+type stateIterator struct {
+	results []*queryresult.KV
+}
+
+func (i *stateIterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *stateIterator) Next() (*queryresult.KV, error) {
+	if len(i.results) == 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+	result := i.results[0]
+	i.results = i.results[1:]
+	return result, nil
+}
+
+func (i *stateIterator) Close() error {
+	return nil
+}
+
+// historyIterator iterates over a slice of key modifications
+type historyIterator struct {
+	results []*queryresult.KeyModification
+}
+
+func (i *historyIterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if len(i.results) == 0 {
+		return nil, fmt.Errorf("no more results")
+	}
+	result := i.results[0]
+	i.results = i.results[1:]
+	return result, nil
+}
+
+func (i *historyIterator) Close() error {
+	return nil
+}
@@ -7,25 +7,40 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"log"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// PaginatedQueryResult structure used for returning paginated query results and metadata
+type PaginatedQueryResult struct {
+	Records             []*Asset `json:"records"`
+	FetchedRecordsCount int32    `json:"fetchedRecordsCount"`
+	Bookmark            string   `json:"bookmark"`
+}
+
 // ReadAsset reads the information from collection
 func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, assetID string) (*Asset, error) {
 
-	log.Printf("ReadAsset: collection %v, ID %v", assetCollection, assetID)
-	assetJSON, err := ctx.GetStub().GetPrivateData(assetCollection, assetID) //get the asset from chaincode state
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("ReadAsset: collection %v, ID %v", config.AssetCollection, assetID)
+	assetJSON, err := ctx.GetStub().GetPrivateData(config.AssetCollection, assetID) //get the asset from chaincode state
 	if err != nil {
 		return nil, fmt.Errorf("failed to read asset: %v", err)
 	}
 
 	//No Asset found, return empty response
 	if assetJSON == nil {
-		log.Printf("%v does not exist in collection %v", assetID, assetCollection)
+		log.Printf("%v does not exist in collection %v", assetID, config.AssetCollection)
 		return nil, nil
 	}
 
@@ -60,27 +75,90 @@ func (s *SmartContract) ReadAssetPrivateDetails(ctx contractapi.TransactionConte
 	return assetDetails, nil
 }
 
-// ReadTransferAgreement gets the buyer's identity from the transfer agreement from collection
+// VerificationResult describes the outcome of checking claimed asset private details against the on-chain hash
+type VerificationResult struct {
+	ID          string `json:"assetID"`
+	Collection  string `json:"collection"`
+	Match       bool   `json:"match"`
+	ClaimedHash string `json:"claimedHash"`
+	OnChainHash string `json:"onChainHash"`
+}
+
+// VerifyAssetPrivateDetails allows an organization that is not a member of a collection to verify
+// asset private details claimed by the owner, such as the appraised value. The claimed JSON is passed
+// in the transient field and its hash is compared with the hash of the private details on the ledger.
+func (s *SmartContract) VerifyAssetPrivateDetails(ctx contractapi.TransactionContextInterface, collection string, assetID string) (*VerificationResult, error) {
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+
+	// Hash the JSON bytes as-is, since they must match the bytes written by the owner
+	claimedJSONasBytes, ok := transientMap[config.TransientKeys.AssetPrivateDetails]
+	if !ok {
+		return nil, fmt.Errorf("asset private details not found in the transient map")
+	}
+
+	log.Printf("VerifyAssetPrivateDetails: collection %v, ID %v", collection, assetID)
+	onChainHash, err := ctx.GetStub().GetPrivateDataHash(collection, assetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hash of asset private details from collection %v: %v", collection, err)
+	}
+	if onChainHash == nil {
+		return nil, fmt.Errorf("hash of asset private details for %v does not exist in collection %v", assetID, collection)
+	}
+
+	claimedHash := sha256.Sum256(claimedJSONasBytes)
+
+	result := &VerificationResult{
+		ID:          assetID,
+		Collection:  collection,
+		Match:       bytes.Equal(onChainHash, claimedHash[:]),
+		ClaimedHash: fmt.Sprintf("%x", claimedHash),
+		OnChainHash: fmt.Sprintf("%x", onChainHash),
+	}
+	return result, nil
+}
+
+// ReadTransferAgreement gets the buyer's identity from the transfer agreement from collection,
+// and the state of the transfer from the public transfer status
 func (s *SmartContract) ReadTransferAgreement(ctx contractapi.TransactionContextInterface, assetID string) (*TransferAgreement, error) {
-	log.Printf("ReadTransferAgreement: collection %v, ID %v", assetCollection, assetID)
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("ReadTransferAgreement: collection %v, ID %v", config.AssetCollection, assetID)
 	// composite key for TransferAgreement of this asset
 	transferAgreeKey, err := ctx.GetStub().CreateCompositeKey(transferAgreementObjectType, []string{assetID})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create composite key: %v", err)
 	}
 
-	buyerIdentity, err := ctx.GetStub().GetPrivateData(assetCollection, transferAgreeKey) // Get the identity from collection
+	buyerIdentity, err := ctx.GetStub().GetPrivateData(config.AssetCollection, transferAgreeKey) // Get the identity from collection
 	if err != nil {
 		return nil, fmt.Errorf("failed to read TransferAgreement: %v", err)
 	}
-	if buyerIdentity == nil {
+
+	agreement, err := readTransferStatus(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if buyerIdentity == nil && agreement.State == "" {
 		log.Printf("TransferAgreement for %v does not exist", assetID)
 		return nil, nil
 	}
-	agreement := &TransferAgreement{
-		ID:      assetID,
-		BuyerID: string(buyerIdentity),
+
+	// Agreements made before transfer states were recorded have no public status
+	if agreement.State == "" {
+		agreement.State = TransferAgreed
 	}
+	agreement.BuyerID = string(buyerIdentity)
 	return agreement, nil
 }
 
@@ -89,7 +167,12 @@ func (s *SmartContract) ReadTransferAgreement(ctx contractapi.TransactionContext
 // a transaction that also writes to private data.
 func (s *SmartContract) GetAssetByRange(ctx contractapi.TransactionContextInterface, startKey string, endKey string) ([]*Asset, error) {
 
-	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(assetCollection, startKey, endKey)
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(config.AssetCollection, startKey, endKey)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +199,114 @@ func (s *SmartContract) GetAssetByRange(ctx contractapi.TransactionContextInterf
 
 }
 
+// QueryPurgedAssets returns the tombstones of all purged asset private details.
+// Tombstones are kept in the world state, so any organization can list them.
+func (s *SmartContract) QueryPurgedAssets(ctx contractapi.TransactionContextInterface) ([]*PurgeTombstone, error) {
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(purgedAssetObjectType, []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	results := []*PurgeTombstone{}
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var tombstone *PurgeTombstone
+		err = json.Unmarshal(response.Value, &tombstone)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+
+		results = append(results, tombstone)
+	}
+
+	return results, nil
+}
+
+// GetAssetByRangeWithPagination performs a range query based on the start and end keys, page size
+// and a bookmark. The bookmark is returned by the previous page and is empty once all assets were read.
+// The number of fetched records will be equal to or lesser than the page size.
+func (s *SmartContract) GetAssetByRangeWithPagination(ctx contractapi.TransactionContextInterface, startKey string, endKey string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be a positive integer")
+	}
+
+	// The bookmark is the last key of the previous page, so the page starts right after it
+	if bookmark != "" {
+		startKey = bookmark + "\x00"
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataByRange(config.AssetCollection, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return getPaginatedResultFromIterator(resultsIterator, pageSize, "")
+}
+
+// QueryAssetsByAppraisedValue queries the org specific collection of the client for the
+// private details of assets with an appraised value in the given inclusive range.
+// Only available on state databases that support rich query (e.g. CouchDB)
+func (s *SmartContract) QueryAssetsByAppraisedValue(ctx contractapi.TransactionContextInterface, minValue int, maxValue int) ([]*AssetPrivateDetails, error) {
+
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	err = verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("QueryAssetsByAppraisedValue cannot be performed: Error %v", err)
+	}
+
+	orgCollection, err := getCollectionName(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to infer private collection name for the org: %v", err)
+	}
+
+	queryString := fmt.Sprintf("{\"selector\":{\"appraisedValue\":{\"$gte\":%d,\"$lte\":%d}}}", minValue, maxValue)
+
+	log.Printf("QueryAssetsByAppraisedValue: collection %v, query %v", orgCollection, queryString)
+	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(orgCollection, queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	results := []*AssetPrivateDetails{}
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var assetDetails *AssetPrivateDetails
+		err = json.Unmarshal(response.Value, &assetDetails)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+
+		results = append(results, assetDetails)
+	}
+
+	return results, nil
+}
+
 // =======Rich queries =========================================================================
 // Two examples of rich queries are provided below (parameterized query and ad hoc query).
 // Rich queries pass a query string to the state database.
@@ -161,10 +352,101 @@ func (s *SmartContract) QueryAssets(ctx contractapi.TransactionContextInterface,
 	return queryResults, nil
 }
 
+// QueryAssetByOwnerWithPagination queries for assets based on assetType, owner, page size and a bookmark.
+// Only available on state databases that support rich query (e.g. CouchDB)
+func (s *SmartContract) QueryAssetByOwnerWithPagination(ctx contractapi.TransactionContextInterface, assetType string, owner string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+
+	queryString := fmt.Sprintf("{\"selector\":{\"objectType\":\"%v\",\"owner\":\"%v\"}}", assetType, owner)
+
+	return s.getQueryResultForQueryStringWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// QueryAssetsWithPagination uses a query string, page size and a bookmark to perform a query for assets.
+// The bookmark is returned by the previous page and is empty once all assets were read.
+// Only available on state databases that support rich query (e.g. CouchDB)
+func (s *SmartContract) QueryAssetsWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+
+	return s.getQueryResultForQueryStringWithPagination(ctx, queryString, pageSize, bookmark)
+}
+
+// getQueryResultForQueryStringWithPagination executes the passed in query string and returns a page of the results.
+func (s *SmartContract) getQueryResultForQueryStringWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("pageSize must be a positive integer")
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(config.AssetCollection, queryString)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	return getPaginatedResultFromIterator(resultsIterator, pageSize, bookmark)
+}
+
+// getPaginatedResultFromIterator reads a page of assets from the iterator.
+// Private data queries do not support pagination on the peer, so the bookmark is the key of the
+// last asset of the previous page, and results up to and including that key are skipped.
+// Query results are not necessarily sorted by key, so a bookmark that is not in the results,
+// for instance because the asset was deleted since, is an error rather than an empty page.
+func getPaginatedResultFromIterator(resultsIterator shim.StateQueryIteratorInterface, pageSize int, bookmark string) (*PaginatedQueryResult, error) {
+
+	results := []*Asset{}
+	skipping := bookmark != ""
+	lastKey := ""
+	nextBookmark := ""
+
+	for resultsIterator.HasNext() {
+		if len(results) == pageSize {
+			nextBookmark = lastKey
+			break
+		}
+
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if skipping {
+			skipping = response.Key != bookmark
+			continue
+		}
+
+		var asset *Asset
+		err = json.Unmarshal(response.Value, &asset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+		}
+
+		results = append(results, asset)
+		lastKey = response.Key
+	}
+
+	if skipping {
+		return nil, fmt.Errorf("bookmark %v not found in the query results, restart the query without a bookmark", bookmark)
+	}
+
+	return &PaginatedQueryResult{
+		Records:             results,
+		FetchedRecordsCount: int32(len(results)),
+		Bookmark:            nextBookmark,
+	}, nil
+}
+
 // getQueryResultForQueryString executes the passed in query string.
 func (s *SmartContract) getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, queryString string) ([]*Asset, error) {
 
-	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(assetCollection, queryString)
+	config, err := readConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(config.AssetCollection, queryString)
 	if err != nil {
 		return nil, err
 	}
@@ -6,9 +6,11 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode_test
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 
@@ -78,13 +80,24 @@ func TestReadTransferAgreement(t *testing.T) {
 	require.Nil(t, assetBytes)
 
 	chaincodeStub.GetPrivateDataReturns([]byte(myOrg2Clientid), nil)
+	//agreement without public transfer status
 	expectedData := &chaincode.TransferAgreement{
 		ID:      "id1",
 		BuyerID: myOrg2Clientid,
+		State:   chaincode.TransferAgreed,
 	}
 	dataRead, err := assetTransferCC.ReadTransferAgreement(transactionContext, "id1")
 	require.NoError(t, err)
 	require.Equal(t, expectedData, dataRead)
+
+	agreedAt := time.Unix(1000, 0).UTC()
+	statusBytes, err := json.Marshal(&chaincode.TransferAgreement{ID: "id1", State: chaincode.TransferAgreed, AgreedAt: agreedAt})
+	require.NoError(t, err)
+	chaincodeStub.GetStateReturns(statusBytes, nil)
+	expectedData.AgreedAt = agreedAt
+	dataRead, err = assetTransferCC.ReadTransferAgreement(transactionContext, "id1")
+	require.NoError(t, err)
+	require.Equal(t, expectedData, dataRead)
 }
 
 func TestQueryAssetByOwner(t *testing.T) {
@@ -183,3 +196,170 @@ func TestGetAssetByRange(t *testing.T) {
 	require.Equal(t, []*chaincode.Asset{asset}, assets)
 
 }
+
+func TestQueryPurgedAssets(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+
+	tombstone := &chaincode.PurgeTombstone{ID: "asset1", Collection: myOrg1PrivCollection, PriorHash: "6461746168617368", TxID: "tx1"}
+	tombstoneBytes, err := json.Marshal(tombstone)
+	require.NoError(t, err)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturnsOnCall(0, true)
+	iterator.HasNextReturnsOnCall(1, false)
+	iterator.NextReturns(&queryresult.KV{Value: tombstoneBytes}, nil)
+	chaincodeStub.GetStateByPartialCompositeKeyReturns(iterator, nil)
+
+	assetTransferCC := &chaincode.SmartContract{}
+	tombstones, err := assetTransferCC.QueryPurgedAssets(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.PurgeTombstone{tombstone}, tombstones)
+
+	objectType, _ := chaincodeStub.GetStateByPartialCompositeKeyArgsForCall(0)
+	require.Equal(t, "purgedAssetPrivateDetails", objectType)
+}
+
+func TestVerifyAssetPrivateDetails(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := &chaincode.SmartContract{}
+
+	_, err := assetTransferCC.VerifyAssetPrivateDetails(transactionContext, myOrg1PrivCollection, "id1")
+	require.EqualError(t, err, "asset private details not found in the transient map")
+
+	claimedBytes, err := json.Marshal(&chaincode.AssetPrivateDetails{ID: "id1", AppraisedValue: 500})
+	require.NoError(t, err)
+	chaincodeStub.GetTransientReturns(map[string][]byte{"asset_private_details": claimedBytes}, nil)
+
+	//private details do not exist
+	_, err = assetTransferCC.VerifyAssetPrivateDetails(transactionContext, myOrg1PrivCollection, "id1")
+	require.EqualError(t, err, "hash of asset private details for id1 does not exist in collection "+myOrg1PrivCollection)
+
+	claimedHash := sha256.Sum256(claimedBytes)
+	chaincodeStub.GetPrivateDataHashReturns(claimedHash[:], nil)
+	result, err := assetTransferCC.VerifyAssetPrivateDetails(transactionContext, myOrg1PrivCollection, "id1")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.VerificationResult{
+		ID:          "id1",
+		Collection:  myOrg1PrivCollection,
+		Match:       true,
+		ClaimedHash: fmt.Sprintf("%x", claimedHash),
+		OnChainHash: fmt.Sprintf("%x", claimedHash),
+	}, result)
+	calledCollection, calledId := chaincodeStub.GetPrivateDataHashArgsForCall(1)
+	require.Equal(t, myOrg1PrivCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+
+	//claimed appraised value differs from the owner's
+	chaincodeStub.GetPrivateDataHashReturns([]byte("datahash"), nil)
+	result, err = assetTransferCC.VerifyAssetPrivateDetails(transactionContext, myOrg1PrivCollection, "id1")
+	require.NoError(t, err)
+	require.False(t, result.Match)
+	require.Equal(t, fmt.Sprintf("%x", "datahash"), result.OnChainHash)
+}
+
+func TestQueryAssetsWithPagination(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := &chaincode.SmartContract{}
+
+	_, err := assetTransferCC.QueryAssetsWithPagination(transactionContext, "querystr", 0, "")
+	require.EqualError(t, err, "pageSize must be a positive integer")
+
+	assets := []*chaincode.Asset{
+		{Type: "valuableasset", ID: "asset1", Owner: "user1"},
+		{Type: "valuableasset", ID: "asset2", Owner: "user1"},
+		{Type: "valuableasset", ID: "asset3", Owner: "user1"},
+	}
+	newIterator := func() *mocks.StateQueryIterator {
+		iterator := &mocks.StateQueryIterator{}
+		for i, asset := range assets {
+			assetBytes, err := json.Marshal(asset)
+			require.NoError(t, err)
+			iterator.HasNextReturnsOnCall(i, true)
+			iterator.NextReturnsOnCall(i, &queryresult.KV{Key: asset.ID, Value: assetBytes}, nil)
+		}
+		iterator.HasNextReturnsOnCall(len(assets), false)
+		return iterator
+	}
+
+	chaincodeStub.GetPrivateDataQueryResultReturns(newIterator(), nil)
+	page, err := assetTransferCC.QueryAssetsWithPagination(transactionContext, "querystr", 2, "")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.PaginatedQueryResult{
+		Records:             assets[:2],
+		FetchedRecordsCount: 2,
+		Bookmark:            "asset2",
+	}, page)
+
+	//next page skips records up to the bookmark and ends the result set
+	chaincodeStub.GetPrivateDataQueryResultReturns(newIterator(), nil)
+	page, err = assetTransferCC.QueryAssetsWithPagination(transactionContext, "querystr", 2, "asset2")
+	require.NoError(t, err)
+	require.Equal(t, &chaincode.PaginatedQueryResult{
+		Records:             assets[2:],
+		FetchedRecordsCount: 1,
+		Bookmark:            "",
+	}, page)
+
+	//bookmark of an asset deleted since the previous page
+	chaincodeStub.GetPrivateDataQueryResultReturns(newIterator(), nil)
+	_, err = assetTransferCC.QueryAssetsWithPagination(transactionContext, "querystr", 2, "asset0")
+	require.EqualError(t, err, "bookmark asset0 not found in the query results, restart the query without a bookmark")
+
+	chaincodeStub.GetPrivateDataQueryResultReturns(newIterator(), nil)
+	page, err = assetTransferCC.QueryAssetByOwnerWithPagination(transactionContext, "valuableasset", "user1", 5, "")
+	require.NoError(t, err)
+	require.Equal(t, assets, page.Records)
+	require.Equal(t, "", page.Bookmark)
+	_, calledQuery := chaincodeStub.GetPrivateDataQueryResultArgsForCall(3)
+	require.Equal(t, `{"selector":{"objectType":"valuableasset","owner":"user1"}}`, calledQuery)
+}
+
+func TestGetAssetByRangeWithPagination(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := &chaincode.SmartContract{}
+
+	asset := &chaincode.Asset{Type: "valuableasset", ID: "asset3", Owner: "user1"}
+	assetBytes, err := json.Marshal(asset)
+	require.NoError(t, err)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturnsOnCall(0, true)
+	iterator.HasNextReturnsOnCall(1, false)
+	iterator.NextReturns(&queryresult.KV{Key: asset.ID, Value: assetBytes}, nil)
+	chaincodeStub.GetPrivateDataByRangeReturns(iterator, nil)
+
+	page, err := assetTransferCC.GetAssetByRangeWithPagination(transactionContext, "asset1", "asset9", 2, "asset2")
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.Asset{asset}, page.Records)
+	require.Equal(t, int32(1), page.FetchedRecordsCount)
+	require.Equal(t, "", page.Bookmark)
+
+	//range starts right after the bookmark
+	calledCollection, calledStartKey, calledEndKey := chaincodeStub.GetPrivateDataByRangeArgsForCall(0)
+	require.Equal(t, assetCollectionName, calledCollection)
+	require.Equal(t, "asset2\x00", calledStartKey)
+	require.Equal(t, "asset9", calledEndKey)
+}
+
+func TestQueryAssetsByAppraisedValue(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := &chaincode.SmartContract{}
+
+	assetDetails := &chaincode.AssetPrivateDetails{ID: "asset1", AppraisedValue: 500}
+	assetDetailsBytes, err := json.Marshal(assetDetails)
+	require.NoError(t, err)
+
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextReturnsOnCall(0, true)
+	iterator.HasNextReturnsOnCall(1, false)
+	iterator.NextReturns(&queryresult.KV{Key: assetDetails.ID, Value: assetDetailsBytes}, nil)
+	chaincodeStub.GetPrivateDataQueryResultReturns(iterator, nil)
+
+	results, err := assetTransferCC.QueryAssetsByAppraisedValue(transactionContext, 100, 1000)
+	require.NoError(t, err)
+	require.Equal(t, []*chaincode.AssetPrivateDetails{assetDetails}, results)
+
+	calledCollection, calledQuery := chaincodeStub.GetPrivateDataQueryResultArgsForCall(0)
+	require.Equal(t, myOrg1PrivCollection, calledCollection)
+	require.Equal(t, `{"selector":{"appraisedValue":{"$gte":100,"$lte":1000}}}`, calledQuery)
+}
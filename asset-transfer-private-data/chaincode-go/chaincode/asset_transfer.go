@@ -12,13 +12,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
+// assetCollection is the default name of the collection shared by all organizations
 const assetCollection = "assetCollection"
 const transferAgreementObjectType = "transferAgreement"
+const purgedAssetObjectType = "purgedAssetPrivateDetails"
 
 // SmartContract of this fabric sample
 type SmartContract struct {
@@ -40,16 +43,47 @@ type AssetPrivateDetails struct {
 	AppraisedValue int    `json:"appraisedValue"`
 }
 
-// TransferAgreement describes the buyer agreement returned by ReadTransferAgreement
+// TransferAgreement describes the buyer agreement returned by ReadTransferAgreement,
+// along with the state of the transfer and the time each state was entered.
+// Timestamps of states that were not entered are zero.
 type TransferAgreement struct {
-	ID      string `json:"assetID"`
-	BuyerID string `json:"buyerID"`
+	ID          string        `json:"assetID"`
+	BuyerID     string        `json:"buyerID"`
+	State       TransferState `json:"state"`
+	ProposedAt  time.Time     `json:"proposedAt"`
+	AgreedAt    time.Time     `json:"agreedAt"`
+	CompletedAt time.Time     `json:"completedAt"`
+	CancelledAt time.Time     `json:"cancelledAt"`
+	ExpiredAt   time.Time     `json:"expiredAt"`
+	ExpiresAt   time.Time     `json:"expiresAt"`
+}
+
+// PurgeTombstone is the public record left behind when asset private details are purged.
+// It proves that the private details existed without revealing them.
+type PurgeTombstone struct {
+	ID         string    `json:"assetID"`
+	Collection string    `json:"collection"`
+	PriorHash  string    `json:"priorHash"`
+	Purged     bool      `json:"purged"` // false if the peer did not support purge and the details were deleted
+	TxID       string    `json:"txID"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// privateDataPurger is implemented by the chaincode stub of shims that support purging private
+// data, from Fabric v2.5
+type privateDataPurger interface {
+	PurgePrivateData(collection, key string) error
 }
 
 // CreateAsset creates a new asset by placing the main asset details in the assetCollection
 // that can be read by both organizations. The appraisal value is stored in the owners org specific collection.
 func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface) error {
 
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Get new asset from transient map
 	transientMap, err := ctx.GetStub().GetTransient()
 	if err != nil {
@@ -57,7 +91,7 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface)
 	}
 
 	// Asset properties are private, therefore they get passed in transient field, instead of func args
-	transientAssetJSON, ok := transientMap["asset_properties"]
+	transientAssetJSON, ok := transientMap[config.TransientKeys.AssetProperties]
 	if !ok {
 		//log error to stdout
 		return fmt.Errorf("asset not found in the transient map input")
@@ -94,7 +128,7 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface)
 	}
 
 	// Check if asset already exists
-	assetAsBytes, err := ctx.GetStub().GetPrivateData(assetCollection, assetInput.ID)
+	assetAsBytes, err := ctx.GetStub().GetPrivateData(config.AssetCollection, assetInput.ID)
 	if err != nil {
 		return fmt.Errorf("failed to get asset: %v", err)
 	} else if assetAsBytes != nil {
@@ -132,9 +166,9 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface)
 	// Save asset to private data collection
 	// Typical logger, logs to stdout/file in the fabric managed docker container, running this chaincode
 	// Look for container name like dev-peer0.org1.example.com-{chaincodename_version}-xyz
-	log.Printf("CreateAsset Put: collection %v, ID %v, owner %v", assetCollection, assetInput.ID, clientID)
+	log.Printf("CreateAsset Put: collection %v, ID %v, owner %v", config.AssetCollection, assetInput.ID, clientID)
 
-	err = ctx.GetStub().PutPrivateData(assetCollection, assetInput.ID, assetJSONasBytes)
+	err = ctx.GetStub().PutPrivateData(config.AssetCollection, assetInput.ID, assetJSONasBytes)
 	if err != nil {
 		return fmt.Errorf("failed to put asset into private data collecton: %v", err)
 	}
@@ -151,7 +185,7 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface)
 	}
 
 	// Get collection name for this organization.
-	orgCollection, err := getCollectionName(ctx)
+	orgCollection, err := getCollectionName(ctx, config)
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
@@ -171,6 +205,11 @@ func (s *SmartContract) CreateAsset(ctx contractapi.TransactionContextInterface)
 // using a composite key
 func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterface) error {
 
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Get ID of submitting client identity
 	clientID, err := submittingClientIdentity(ctx)
 	if err != nil {
@@ -184,7 +223,7 @@ func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterf
 	}
 
 	// Persist the JSON bytes as-is so that there is no risk of nondeterministic marshaling.
-	valueJSONasBytes, ok := transientMap["asset_value"]
+	valueJSONasBytes, ok := transientMap[config.TransientKeys.AssetValue]
 	if !ok {
 		return fmt.Errorf("asset_value key not found in the transient map")
 	}
@@ -219,7 +258,7 @@ func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterf
 	}
 
 	// Get collection name for this organization. Needs to be read by a member of the organization.
-	orgCollection, err := getCollectionName(ctx)
+	orgCollection, err := getCollectionName(ctx, config)
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
@@ -239,25 +278,50 @@ func (s *SmartContract) AgreeToTransfer(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("failed to create composite key: %v", err)
 	}
 
-	log.Printf("AgreeToTransfer Put: collection %v, ID %v, Key %v", assetCollection, valueJSON.ID, transferAgreeKey)
-	err = ctx.GetStub().PutPrivateData(assetCollection, transferAgreeKey, []byte(clientID))
+	log.Printf("AgreeToTransfer Put: collection %v, ID %v, Key %v", config.AssetCollection, valueJSON.ID, transferAgreeKey)
+	err = ctx.GetStub().PutPrivateData(config.AssetCollection, transferAgreeKey, []byte(clientID))
 	if err != nil {
 		return fmt.Errorf("failed to put asset bid: %v", err)
 	}
 
-	return nil
+	// Record the agreement in the public transfer status. The buyer can agree to a
+	// transfer proposed by the owner, or start a new transfer.
+	status, err := readTransferStatus(ctx, valueJSON.ID)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if status.isExpired(now) {
+		return fmt.Errorf("transfer of %v expired at %v", valueJSON.ID, status.ExpiresAt)
+	}
+
+	// A new transfer starts without the expiry and timestamps of the previous one
+	if !status.isActive() {
+		status = &TransferAgreement{ID: valueJSON.ID, State: status.State}
+	}
+
+	return setTransferState(ctx, status, TransferAgreed, now)
 }
 
 // TransferAsset transfers the asset to the new owner by setting a new owner ID
 func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterface) error {
 
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
 	transientMap, err := ctx.GetStub().GetTransient()
 	if err != nil {
 		return fmt.Errorf("error getting transient %v", err)
 	}
 
 	// Asset properties are private, therefore they get passed in transient field
-	transientTransferJSON, ok := transientMap["asset_owner"]
+	transientTransferJSON, ok := transientMap[config.TransientKeys.AssetOwner]
 	if !ok {
 		return fmt.Errorf("asset owner not found in the transient map")
 	}
@@ -295,7 +359,7 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 	}
 
 	// Verify transfer details and transfer owner
-	err = s.verifyAgreement(ctx, assetTransferInput.ID, asset.Owner, assetTransferInput.BuyerMSP)
+	err = s.verifyAgreement(ctx, config, assetTransferInput.ID, asset.Owner, assetTransferInput.BuyerMSP)
 	if err != nil {
 		return fmt.Errorf("failed transfer verification: %v", err)
 	}
@@ -304,10 +368,23 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 	if err != nil {
 		return fmt.Errorf("failed ReadTransferAgreement to find buyerID: %v", err)
 	}
-	if transferAgreement.BuyerID == "" {
+	if transferAgreement == nil || transferAgreement.BuyerID == "" {
 		return fmt.Errorf("BuyerID not found in TransferAgreement for %v", assetTransferInput.ID)
 	}
 
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	if transferAgreement.isExpired(now) {
+		return fmt.Errorf("transfer of %v expired at %v", assetTransferInput.ID, transferAgreement.ExpiresAt)
+	}
+
+	err = setTransferState(ctx, transferAgreement, TransferCompleted, now)
+	if err != nil {
+		return err
+	}
+
 	// Transfer asset in private data collection to new owner
 	asset.Owner = transferAgreement.BuyerID
 
@@ -316,14 +393,14 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("failed marshalling asset %v: %v", assetTransferInput.ID, err)
 	}
 
-	log.Printf("TransferAsset Put: collection %v, ID %v", assetCollection, assetTransferInput.ID)
-	err = ctx.GetStub().PutPrivateData(assetCollection, assetTransferInput.ID, assetJSONasBytes) //rewrite the asset
+	log.Printf("TransferAsset Put: collection %v, ID %v", config.AssetCollection, assetTransferInput.ID)
+	err = ctx.GetStub().PutPrivateData(config.AssetCollection, assetTransferInput.ID, assetJSONasBytes) //rewrite the asset
 	if err != nil {
 		return err
 	}
 
 	// Get collection name for this organization
-	ownersCollection, err := getCollectionName(ctx)
+	ownersCollection, err := getCollectionName(ctx, config)
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
@@ -340,7 +417,7 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("failed to create composite key: %v", err)
 	}
 
-	err = ctx.GetStub().DelPrivateData(assetCollection, transferAgreeKey)
+	err = ctx.GetStub().DelPrivateData(config.AssetCollection, transferAgreeKey)
 	if err != nil {
 		return err
 	}
@@ -352,7 +429,7 @@ func (s *SmartContract) TransferAsset(ctx contractapi.TransactionContextInterfac
 // verifyAgreement is an internal helper function used by TransferAsset to verify
 // that the transfer is being initiated by the owner and that the buyer has agreed
 // to the same appraisal value as the owner
-func (s *SmartContract) verifyAgreement(ctx contractapi.TransactionContextInterface, assetID string, owner string, buyerMSP string) error {
+func (s *SmartContract) verifyAgreement(ctx contractapi.TransactionContextInterface, config *CollectionConfig, assetID string, owner string, buyerMSP string) error {
 
 	// Check 1: verify that the transfer is being initiatied by the owner
 
@@ -369,12 +446,12 @@ func (s *SmartContract) verifyAgreement(ctx contractapi.TransactionContextInterf
 	// Check 2: verify that the buyer has agreed to the appraised value
 
 	// Get collection names
-	collectionOwner, err := getCollectionName(ctx) // get owner collection from caller identity
+	collectionOwner, err := getCollectionName(ctx, config) // get owner collection from caller identity
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
 
-	collectionBuyer := buyerMSP + "PrivateCollection" // get buyers collection
+	collectionBuyer := config.orgCollection(buyerMSP) // get buyers collection
 
 	// Get hash of owners agreed to value
 	ownerAppraisedValueHash, err := ctx.GetStub().GetPrivateDataHash(collectionOwner, assetID)
@@ -405,13 +482,18 @@ func (s *SmartContract) verifyAgreement(ctx contractapi.TransactionContextInterf
 // DeleteAsset can be used by the owner of the asset to delete the asset
 func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface) error {
 
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
 	transientMap, err := ctx.GetStub().GetTransient()
 	if err != nil {
 		return fmt.Errorf("Error getting transient: %v", err)
 	}
 
 	// Asset properties are private, therefore they get passed in transient field
-	transientDeleteJSON, ok := transientMap["asset_delete"]
+	transientDeleteJSON, ok := transientMap[config.TransientKeys.AssetDelete]
 	if !ok {
 		return fmt.Errorf("asset to delete not found in the transient map")
 	}
@@ -437,7 +519,7 @@ func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface)
 	}
 
 	log.Printf("Deleting Asset: %v", assetDeleteInput.ID)
-	valAsbytes, err := ctx.GetStub().GetPrivateData(assetCollection, assetDeleteInput.ID) //get the asset from chaincode state
+	valAsbytes, err := ctx.GetStub().GetPrivateData(config.AssetCollection, assetDeleteInput.ID) //get the asset from chaincode state
 	if err != nil {
 		return fmt.Errorf("failed to read asset: %v", err)
 	}
@@ -445,7 +527,7 @@ func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface)
 		return fmt.Errorf("asset not found: %v", assetDeleteInput.ID)
 	}
 
-	ownerCollection, err := getCollectionName(ctx) // Get owners collection
+	ownerCollection, err := getCollectionName(ctx, config) // Get owners collection
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
@@ -460,7 +542,7 @@ func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface)
 	}
 
 	// delete the asset from state
-	err = ctx.GetStub().DelPrivateData(assetCollection, assetDeleteInput.ID)
+	err = ctx.GetStub().DelPrivateData(config.AssetCollection, assetDeleteInput.ID)
 	if err != nil {
 		return fmt.Errorf("failed to delete state: %v", err)
 	}
@@ -476,16 +558,22 @@ func (s *SmartContract) DeleteAsset(ctx contractapi.TransactionContextInterface)
 }
 
 // DeleteTranferAgreement can be used by the buyer to withdraw a proposal from
-// the asset collection and from his own collection.
+// the asset collection and from his own collection. Only the buyer who agreed
+// to the transfer can withdraw it.
 func (s *SmartContract) DeleteTranferAgreement(ctx contractapi.TransactionContextInterface) error {
 
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
 	transientMap, err := ctx.GetStub().GetTransient()
 	if err != nil {
 		return fmt.Errorf("error getting transient: %v", err)
 	}
 
 	// Asset properties are private, therefore they get passed in transient field
-	transientDeleteJSON, ok := transientMap["agreement_delete"]
+	transientDeleteJSON, ok := transientMap[config.TransientKeys.AgreementDelete]
 	if !ok {
 		return fmt.Errorf("asset to delete not found in the transient map")
 	}
@@ -510,7 +598,7 @@ func (s *SmartContract) DeleteTranferAgreement(ctx contractapi.TransactionContex
 		return fmt.Errorf("DeleteTranferAgreement cannot be performed: Error %v", err)
 	}
 	// Delete private details of agreement
-	orgCollection, err := getCollectionName(ctx) // Get proposers collection.
+	orgCollection, err := getCollectionName(ctx, config) // Get proposers collection.
 	if err != nil {
 		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
 	}
@@ -520,7 +608,7 @@ func (s *SmartContract) DeleteTranferAgreement(ctx contractapi.TransactionContex
 		return fmt.Errorf("failed to create composite key: %v", err)
 	}
 
-	valAsbytes, err := ctx.GetStub().GetPrivateData(assetCollection, tranferAgreeKey) //get the transfer_agreement
+	valAsbytes, err := ctx.GetStub().GetPrivateData(config.AssetCollection, tranferAgreeKey) //get the transfer_agreement
 	if err != nil {
 		return fmt.Errorf("failed to read transfer_agreement: %v", err)
 	}
@@ -528,6 +616,15 @@ func (s *SmartContract) DeleteTranferAgreement(ctx contractapi.TransactionContex
 		return fmt.Errorf("asset's transfer_agreement does not exist: %v", assetDeleteInput.ID)
 	}
 
+	// Only the buyer who agreed to the transfer can withdraw the agreement
+	clientID, err := submittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	if clientID != string(valAsbytes) {
+		return fmt.Errorf("error: submitting client identity did not agree to the transfer of %v", assetDeleteInput.ID)
+	}
+
 	log.Printf("Deleting TranferAgreement: %v", assetDeleteInput.ID)
 	err = ctx.GetStub().DelPrivateData(orgCollection, assetDeleteInput.ID) // Delete the asset
 	if err != nil {
@@ -535,17 +632,132 @@ func (s *SmartContract) DeleteTranferAgreement(ctx contractapi.TransactionContex
 	}
 
 	// Delete transfer agreement record
-	err = ctx.GetStub().DelPrivateData(assetCollection, tranferAgreeKey) // remove agreement from state
+	err = ctx.GetStub().DelPrivateData(config.AssetCollection, tranferAgreeKey) // remove agreement from state
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Withdrawing the agreement cancels a transfer started by the buyer. A transfer proposed
+	// by the owner goes back to PROPOSED, since only the owner can cancel it.
+	status, err := readTransferStatus(ctx, assetDeleteInput.ID)
+	if err != nil {
+		return err
+	}
+	if !status.isActive() {
+		return nil
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if status.State == TransferAgreed && !status.ProposedAt.IsZero() {
+		return withdrawAgreement(ctx, status, now)
+	}
+
+	return setTransferState(ctx, status, TransferCancelled, now)
+
+}
+
+// PurgeAssetPrivateDetails can be used by the owner of the asset to erase the asset private details
+// from the owner's org specific collection. Once the asset is deleted, any client of an org can erase
+// the private details left in the collection of its own org, which is the only collection it can erase from.
+// The private details are purged, so that they are removed from the private data history as well,
+// when the chaincode shim supports it. Otherwise they are deleted: they leave the current state but
+// stay in the private data history until the collection blockToLive.
+// A public tombstone keeps the hash of the erased details.
+func (s *SmartContract) PurgeAssetPrivateDetails(ctx contractapi.TransactionContextInterface, assetID string) error {
+
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(assetID) == 0 {
+		return fmt.Errorf("assetID must be a non-empty string")
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	err = verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("PurgeAssetPrivateDetails cannot be performed: Error %v", err)
+	}
 
+	ownerCollection, err := getCollectionName(ctx, config) // Get owners collection
+	if err != nil {
+		return fmt.Errorf("failed to infer private collection name for the org: %v", err)
+	}
+
+	// Only the owner can purge the private details of an existing asset
+	asset, err := s.ReadAsset(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("error reading asset: %v", err)
+	}
+	if asset != nil {
+		clientID, err := submittingClientIdentity(ctx)
+		if err != nil {
+			return err
+		}
+		if clientID != asset.Owner {
+			return fmt.Errorf("error: submitting client identity does not own asset")
+		}
+	}
+
+	priorHash, err := ctx.GetStub().GetPrivateDataHash(ownerCollection, assetID)
+	if err != nil {
+		return fmt.Errorf("failed to get hash of asset private details from collection %v: %v", ownerCollection, err)
+	}
+	if priorHash == nil {
+		return fmt.Errorf("asset private details not found in owner's private Collection %v: %v", ownerCollection, assetID)
+	}
+
+	purger, purged := ctx.GetStub().(privateDataPurger)
+	if purged {
+		log.Printf("Purging AssetPrivateDetails: collection %v, ID %v", ownerCollection, assetID)
+		err = purger.PurgePrivateData(ownerCollection, assetID)
+	} else {
+		log.Printf("Purge not supported, deleting AssetPrivateDetails: collection %v, ID %v", ownerCollection, assetID)
+		err = ctx.GetStub().DelPrivateData(ownerCollection, assetID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove asset private details: %v", err)
+	}
+
+	timestamp, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	tombstone := PurgeTombstone{
+		ID:         assetID,
+		Collection: ownerCollection,
+		PriorHash:  fmt.Sprintf("%x", priorHash),
+		Purged:     purged,
+		TxID:       ctx.GetStub().GetTxID(),
+		Timestamp:  timestamp,
+	}
+	tombstoneJSONasBytes, err := json.Marshal(tombstone)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tombstone into JSON: %v", err)
+	}
+
+	// The tombstone is keyed by transaction as well, since an asset ID can be reused after deletion
+	tombstoneKey, err := ctx.GetStub().CreateCompositeKey(purgedAssetObjectType, []string{assetID, tombstone.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	err = ctx.GetStub().PutState(tombstoneKey, tombstoneJSONasBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put tombstone: %v", err)
+	}
+
+	return nil
 }
 
 // getCollectionName is an internal helper function to get collection of submitting client identity.
-func getCollectionName(ctx contractapi.TransactionContextInterface) (string, error) {
+func getCollectionName(ctx contractapi.TransactionContextInterface, config *CollectionConfig) (string, error) {
 
 	// Get the MSP ID of submitting client identity
 	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
@@ -554,7 +766,7 @@ func getCollectionName(ctx contractapi.TransactionContextInterface) (string, err
 	}
 
 	// Create the collection name
-	orgCollection := clientMSPID + "PrivateCollection"
+	orgCollection := config.orgCollection(clientMSPID)
 
 	return orgCollection, nil
 }
@@ -6,10 +6,13 @@ SPDX-License-Identifier: Apache-2.0
 package chaincode_test
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"os"
 	"testing"
 
+	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -279,6 +282,8 @@ func TestTransferAssetSuccessful(t *testing.T) {
 	require.Equal(t, assetCollectionName, calledCollection)
 	require.Equal(t, transferAgreementObjectType+"id1", calledId)
 
+	status := requireTransferStatusPut(t, chaincodeStub, chaincode.TransferCompleted)
+	require.Equal(t, txTime, status.CompletedAt)
 }
 
 func TestTransferAssetByNonOwner(t *testing.T) {
@@ -355,6 +360,85 @@ func TestTransferAssetNonMatchingAppraisalValue(t *testing.T) {
 	require.Contains(t, err.Error(), "failed transfer verification: hash for appraised value")
 }
 
+func TestPurgeAssetPrivateDetails(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	err := assetTransferCC.PurgeAssetPrivateDetails(transactionContext, "")
+	require.EqualError(t, err, "assetID must be a non-empty string")
+
+	//Try to purge details of asset owned by Org2
+	org2Asset := chaincode.Asset{
+		ID:    "id1",
+		Type:  "testfulasset",
+		Color: "gray",
+		Size:  7,
+		Owner: myOrg2Clientid,
+	}
+	setReturnPrivateDataInStub(t, chaincodeStub, &org2Asset)
+	err = assetTransferCC.PurgeAssetPrivateDetails(transactionContext, "id1")
+	require.EqualError(t, err, "error: submitting client identity does not own asset")
+
+	//asset does not exist and private details were already removed
+	setReturnPrivateDataInStub(t, chaincodeStub, nil)
+	err = assetTransferCC.PurgeAssetPrivateDetails(transactionContext, "id1")
+	require.EqualError(t, err, "asset private details not found in owner's private Collection "+myOrg1PrivCollection+": id1")
+
+	orgAsset := org2Asset
+	orgAsset.Owner = myOrg1Clientid
+	setReturnPrivateDataInStub(t, chaincodeStub, &orgAsset)
+	chaincodeStub.GetPrivateDataHashReturns([]byte("datahash"), nil)
+	chaincodeStub.GetTxIDReturns("tx1")
+	chaincodeStub.CreateCompositeKeyReturns("purgedAssetPrivateDetailsid1tx1", nil)
+
+	err = assetTransferCC.PurgeAssetPrivateDetails(transactionContext, "id1")
+	require.NoError(t, err)
+
+	//the mock stub does not support purge, so private details are deleted
+	calledCollection, calledId := chaincodeStub.DelPrivateDataArgsForCall(0)
+	require.Equal(t, myOrg1PrivCollection, calledCollection)
+	require.Equal(t, "id1", calledId)
+
+	calledKey, calledTombstoneBytes := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, "purgedAssetPrivateDetailsid1tx1", calledKey)
+	var tombstone chaincode.PurgeTombstone
+	require.NoError(t, json.Unmarshal(calledTombstoneBytes, &tombstone))
+	require.Equal(t, "id1", tombstone.ID)
+	require.Equal(t, myOrg1PrivCollection, tombstone.Collection)
+	require.Equal(t, fmt.Sprintf("%x", "datahash"), tombstone.PriorHash)
+	require.False(t, tombstone.Purged)
+	require.Equal(t, "tx1", tombstone.TxID)
+
+	//asset was deleted, private details left in the collection of the caller's org are purged
+	//with a stub that supports purge
+	setReturnPrivateDataInStub(t, chaincodeStub, nil)
+	purgingStub := &purgingChaincodeStub{ChaincodeStub: chaincodeStub}
+	transactionContext.GetStubReturns(purgingStub)
+	err = assetTransferCC.PurgeAssetPrivateDetails(transactionContext, "id1")
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{myOrg1PrivCollection, "id1"}}, purgingStub.purged)
+	require.Equal(t, 1, chaincodeStub.DelPrivateDataCallCount())
+	_, calledTombstoneBytes = chaincodeStub.PutStateArgsForCall(1)
+	require.NoError(t, json.Unmarshal(calledTombstoneBytes, &tombstone))
+	require.True(t, tombstone.Purged)
+
+	//client of another org cannot purge on this org's peer
+	transactionContext.GetClientIdentity().(*mocks.ClientIdentity).GetMSPIDReturns(myOrg2Msp, nil)
+	err = assetTransferCC.PurgeAssetPrivateDetails(transactionContext, "id1")
+	require.EqualError(t, err, "PurgeAssetPrivateDetails cannot be performed: Error client from org "+myOrg2Msp+" is not authorized to read or write private data from an org "+myOrg1Msp+" peer")
+}
+
+// purgingChaincodeStub is a chaincode stub of a shim that supports purging private data
+type purgingChaincodeStub struct {
+	*mocks.ChaincodeStub
+	purged [][]string
+}
+
+func (s *purgingChaincodeStub) PurgePrivateData(collection, key string) error {
+	s.purged = append(s.purged, []string{collection, key})
+	return nil
+}
+
 func prepMocksAsOrg1() (*mocks.TransactionContext, *mocks.ChaincodeStub) {
 	return prepMocks(myOrg1Msp, myOrg1Clientid)
 }
@@ -368,7 +452,9 @@ func prepMocks(orgMSP, clientId string) (*mocks.TransactionContext, *mocks.Chain
 
 	clientIdentity := &mocks.ClientIdentity{}
 	clientIdentity.GetMSPIDReturns(orgMSP, nil)
-	clientIdentity.GetIDReturns(clientId, nil)
+	//client identity IDs are base64 encoded, as returned by the client identity library
+	clientIdentity.GetIDReturns(base64.StdEncoding.EncodeToString([]byte(clientId)), nil)
+	chaincodeStub.GetTxTimestampReturns(&timestamp.Timestamp{Seconds: 1000}, nil)
 	//set matching msp ID using peer shim env variable
 	os.Setenv("CORE_PEER_LOCALMSPID", orgMSP)
 	transactionContext.GetClientIdentityReturns(clientIdentity)
@@ -0,0 +1,285 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// configKey is the world state key of the collection config record
+const configKey = "collectionConfig"
+
+// CollectionConfig maps the roles used by this contract to the collection names and
+// transient map keys of the channel. It is kept in the world state so that the same
+// chaincode package can serve channels with different collection layouts.
+type CollectionConfig struct {
+	// AssetCollection is shared by all organizations and holds the main asset details
+	AssetCollection string `json:"assetCollection"`
+	// OrgCollectionSuffix is appended to an org MSP ID to get the org specific collection
+	OrgCollectionSuffix string        `json:"orgCollectionSuffix"`
+	TransientKeys       TransientKeys `json:"transientKeys"`
+	// GovernanceOrgs must all endorse updates of the config once it is set. It must include
+	// the org of the client that sets the config.
+	GovernanceOrgs []string `json:"governanceOrgs"`
+}
+
+// TransientKeys are the keys under which functions expect their private input in the transient map
+type TransientKeys struct {
+	AssetProperties string `json:"assetProperties"`
+	AssetValue      string `json:"assetValue"`
+	AssetOwner      string `json:"assetOwner"`
+	AssetDelete     string `json:"assetDelete"`
+	AgreementDelete string `json:"agreementDelete"`
+	// AssetPrivateDetails holds the claimed private details checked by VerifyAssetPrivateDetails
+	AssetPrivateDetails string `json:"assetPrivateDetails"`
+}
+
+// defaultConfig returns the collection layout of collections_config.json.
+// It is used until a config record is set on the channel.
+func defaultConfig() *CollectionConfig {
+	return &CollectionConfig{
+		AssetCollection:     assetCollection,
+		OrgCollectionSuffix: "PrivateCollection",
+		TransientKeys: TransientKeys{
+			AssetProperties:     "asset_properties",
+			AssetValue:          "asset_value",
+			AssetOwner:          "asset_owner",
+			AssetDelete:         "asset_delete",
+			AgreementDelete:     "agreement_delete",
+			AssetPrivateDetails: "asset_private_details",
+		},
+	}
+}
+
+// orgCollection returns the name of the org specific collection of an MSP
+func (c *CollectionConfig) orgCollection(mspID string) string {
+	return mspID + c.OrgCollectionSuffix
+}
+
+func (c *CollectionConfig) validate() error {
+	if len(c.AssetCollection) == 0 {
+		return fmt.Errorf("assetCollection field must be a non-empty string")
+	}
+	if len(c.OrgCollectionSuffix) == 0 {
+		return fmt.Errorf("orgCollectionSuffix field must be a non-empty string")
+	}
+
+	keys := map[string]string{
+		"assetProperties":     c.TransientKeys.AssetProperties,
+		"assetValue":          c.TransientKeys.AssetValue,
+		"assetOwner":          c.TransientKeys.AssetOwner,
+		"assetDelete":         c.TransientKeys.AssetDelete,
+		"agreementDelete":     c.TransientKeys.AgreementDelete,
+		"assetPrivateDetails": c.TransientKeys.AssetPrivateDetails,
+	}
+	for field, key := range keys {
+		if len(key) == 0 {
+			return fmt.Errorf("transientKeys.%s field must be a non-empty string", field)
+		}
+	}
+
+	return nil
+}
+
+// initConfigFunction is the function of the initialization transaction that sets the
+// collection config, see Chaincode.Init
+const initConfigFunction = "InitConfig"
+
+// Chaincode is the private data asset transfer chaincode. It runs the SmartContract, and sets
+// the collection config when the chaincode is initialized.
+type Chaincode struct {
+	*contractapi.ContractChaincode
+}
+
+// NewChaincode returns the private data asset transfer chaincode
+func NewChaincode() (*Chaincode, error) {
+	contractChaincode, err := contractapi.NewChaincode(&SmartContract{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chaincode{ContractChaincode: contractChaincode}, nil
+}
+
+// Start starts the chaincode, with the Init of Chaincode rather than the one of the contract
+func (cc *Chaincode) Start() error {
+	return shim.Start(cc)
+}
+
+// Init is only called by the peer for the initialization transaction of a chaincode definition
+// committed with --init-required, which is the first transaction of the chaincode and is only
+// accepted once. Called with InitConfig and the config JSON, by the org that deploys the chaincode
+// with peer chaincode invoke --isInit -c '{"function":"InitConfig","Args":["<config JSON>"]}',
+// it sets the collection config with SmartContract.InitConfig. Other functions are run by the
+// contract. Until it is initialized with InitConfig, the chaincode uses the default config.
+func (cc *Chaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	function, params := stub.GetFunctionAndParameters()
+	if function != initConfigFunction {
+		return cc.ContractChaincode.Init(stub)
+	}
+	if len(params) != 1 {
+		return shim.Error(fmt.Sprintf("%s takes the config JSON", initConfigFunction))
+	}
+
+	clientIdentity, err := cid.New(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("failed to get client identity: %v", err))
+	}
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(clientIdentity)
+
+	err = (&SmartContract{}).InitConfig(ctx, params[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(nil)
+}
+
+// GetIgnoredFunctions keeps InitConfig out of the transaction functions of the contract,
+// so that it can only be called by the initialization transaction
+func (s *SmartContract) GetIgnoredFunctions() []string {
+	return []string{initConfigFunction}
+}
+
+// InitConfig sets the collection config of the channel. Fields omitted from configJSON
+// keep their default value. It is called by Chaincode.Init, so it can only be called by the
+// initialization transaction of the chaincode, use UpdateConfig afterwards.
+// The client must submit to a peer of its org, and be from one of the governance orgs of the config.
+func (s *SmartContract) InitConfig(ctx contractapi.TransactionContextInterface, configJSON string) error {
+	// Verify that the client is submitting request to peer in their organization
+	err := verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("InitConfig cannot be performed: Error %v", err)
+	}
+
+	configBytes, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	if configBytes != nil {
+		return fmt.Errorf("config is already set, use UpdateConfig to change it")
+	}
+
+	return putConfig(ctx, configJSON)
+}
+
+// UpdateConfig replaces the collection config of the channel. The update must be endorsed
+// by a peer of each governance org.
+func (s *SmartContract) UpdateConfig(ctx contractapi.TransactionContextInterface, configJSON string) error {
+	// Verify that the client is submitting request to peer in their organization
+	err := verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateConfig cannot be performed: Error %v", err)
+	}
+
+	configBytes, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	if configBytes == nil {
+		return fmt.Errorf("config is not set, use InitConfig to set it")
+	}
+
+	return putConfig(ctx, configJSON)
+}
+
+// ReadConfig returns the collection config in effect on the channel
+func (s *SmartContract) ReadConfig(ctx contractapi.TransactionContextInterface) (*CollectionConfig, error) {
+	return readConfig(ctx)
+}
+
+// readConfig is an internal helper function that returns the config record, or the default
+// config if none was set
+func readConfig(ctx contractapi.TransactionContextInterface) (*CollectionConfig, error) {
+	configBytes, err := ctx.GetStub().GetState(configKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	config := defaultConfig()
+	if configBytes == nil {
+		return config, nil
+	}
+
+	// Fields added after the record was set keep their default value
+	err = json.Unmarshal(configBytes, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config JSON: %v", err)
+	}
+
+	return config, nil
+}
+
+// putConfig is an internal helper function that validates and stores the config record,
+// and restricts its future updates to the governance orgs
+func putConfig(ctx contractapi.TransactionContextInterface, configJSON string) error {
+	config := defaultConfig()
+	err := json.Unmarshal([]byte(configJSON), config)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+
+	err = config.validate()
+	if err != nil {
+		return err
+	}
+
+	// The config must stay updatable, and a client cannot hand its governance over to other orgs only
+	if len(config.GovernanceOrgs) == 0 {
+		return fmt.Errorf("governanceOrgs field must be a non-empty list")
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed getting the client's MSPID: %v", err)
+	}
+	governanceOrg := false
+	for _, org := range config.GovernanceOrgs {
+		governanceOrg = governanceOrg || org == clientMSPID
+	}
+	if !governanceOrg {
+		return fmt.Errorf("client from org %v is not one of the governance orgs %v", clientMSPID, config.GovernanceOrgs)
+	}
+
+	configBytes, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config into JSON: %v", err)
+	}
+
+	log.Printf("Put config: asset collection %v, org collection suffix %v", config.AssetCollection, config.OrgCollectionSuffix)
+	err = ctx.GetStub().PutState(configKey, configBytes)
+	if err != nil {
+		return fmt.Errorf("failed to put config: %v", err)
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, config.GovernanceOrgs...)
+	if err != nil {
+		return fmt.Errorf("failed to add orgs to endorsement policy: %v", err)
+	}
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to create endorsement policy bytes from orgs: %v", err)
+	}
+	err = ctx.GetStub().SetStateValidationParameter(configKey, policy)
+	if err != nil {
+		return fmt.Errorf("failed to set validation parameter on config: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+package chaincode_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+For details on generating the mocks, see comments in the file asset_transfer_test.go
+*/
+const configKey = "collectionConfig"
+
+func TestReadConfigDefault(t *testing.T) {
+	transactionContext, _ := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	config, err := assetTransferCC.ReadConfig(transactionContext)
+	require.NoError(t, err)
+	require.Equal(t, assetCollectionName, config.AssetCollection)
+	require.Equal(t, "PrivateCollection", config.OrgCollectionSuffix)
+	require.Equal(t, "asset_properties", config.TransientKeys.AssetProperties)
+	require.Equal(t, "asset_owner", config.TransientKeys.AssetOwner)
+	require.Equal(t, "asset_delete", config.TransientKeys.AssetDelete)
+}
+
+func TestInitConfig(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	err := assetTransferCC.InitConfig(transactionContext, `{"assetCollection":""}`)
+	require.EqualError(t, err, "assetCollection field must be a non-empty string")
+
+	err = assetTransferCC.InitConfig(transactionContext, `{"transientKeys":{"assetOwner":""}}`)
+	require.EqualError(t, err, "transientKeys.assetOwner field must be a non-empty string")
+
+	err = assetTransferCC.InitConfig(transactionContext, `{}`)
+	require.EqualError(t, err, "governanceOrgs field must be a non-empty list")
+
+	err = assetTransferCC.InitConfig(transactionContext, `{"governanceOrgs":["Org2Testmsp"]}`)
+	require.EqualError(t, err, "client from org Org1Testmsp is not one of the governance orgs [Org2Testmsp]")
+
+	err = assetTransferCC.InitConfig(transactionContext, `{"assetCollection":"sharedAssets","governanceOrgs":["Org1Testmsp","Org2Testmsp"]}`)
+	require.NoError(t, err)
+
+	calledKey, calledConfigBytes := chaincodeStub.PutStateArgsForCall(0)
+	require.Equal(t, configKey, calledKey)
+	var config chaincode.CollectionConfig
+	require.NoError(t, json.Unmarshal(calledConfigBytes, &config))
+	require.Equal(t, "sharedAssets", config.AssetCollection)
+	// omitted fields keep their default value
+	require.Equal(t, "PrivateCollection", config.OrgCollectionSuffix)
+	require.Equal(t, "asset_properties", config.TransientKeys.AssetProperties)
+
+	calledKey, _ = chaincodeStub.SetStateValidationParameterArgsForCall(0)
+	require.Equal(t, configKey, calledKey)
+
+	// config already set
+	chaincodeStub.GetStateReturns(calledConfigBytes, nil)
+	err = assetTransferCC.InitConfig(transactionContext, `{}`)
+	require.EqualError(t, err, "config is already set, use UpdateConfig to change it")
+
+	err = assetTransferCC.UpdateConfig(transactionContext, `{"assetCollection":"otherAssets","governanceOrgs":["Org1Testmsp"]}`)
+	require.NoError(t, err)
+}
+
+func TestInitConfigFromOtherOrgPeer(t *testing.T) {
+	transactionContext, _ := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	os.Setenv("CORE_PEER_LOCALMSPID", myOrg2Msp)
+	err := assetTransferCC.InitConfig(transactionContext, `{}`)
+	require.EqualError(t, err, "InitConfig cannot be performed: Error client from org Org1Testmsp is not authorized to read or write private data from an org Org2Testmsp peer")
+}
+
+func TestInitConfigOnlyInInitialization(t *testing.T) {
+	_, chaincodeStub := prepMocksAsOrg1()
+	assetChaincode, err := chaincode.NewChaincode()
+	require.NoError(t, err)
+
+	// InitConfig is not a transaction function of the contract
+	chaincodeStub.GetFunctionAndParametersReturns("InitConfig", []string{`{"governanceOrgs":["Org1Testmsp"]}`})
+	response := assetChaincode.Invoke(chaincodeStub)
+	require.Equal(t, "Function InitConfig not found in contract SmartContract", response.Message)
+	require.Equal(t, 0, chaincodeStub.PutStateCallCount())
+
+	chaincodeStub.GetFunctionAndParametersReturns("InitConfig", nil)
+	response = assetChaincode.Init(chaincodeStub)
+	require.Equal(t, "InitConfig takes the config JSON", response.Message)
+
+	// Other functions are run by the contract
+	chaincodeStub.GetFunctionAndParametersReturns("", nil)
+	response = assetChaincode.Init(chaincodeStub)
+	require.Equal(t, int32(shim.OK), response.Status)
+}
+
+func TestUpdateConfigWithoutGovernanceOrgs(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	chaincodeStub.GetStateReturns([]byte(`{"assetCollection":"sharedAssets","governanceOrgs":["Org1Testmsp"]}`), nil)
+	err := assetTransferCC.UpdateConfig(transactionContext, `{"governanceOrgs":[]}`)
+	require.EqualError(t, err, "governanceOrgs field must be a non-empty list")
+	require.Equal(t, 0, chaincodeStub.PutStateCallCount())
+}
+
+func TestUpdateConfigNotSet(t *testing.T) {
+	transactionContext, _ := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	err := assetTransferCC.UpdateConfig(transactionContext, `{}`)
+	require.EqualError(t, err, "config is not set, use InitConfig to set it")
+}
+
+func TestCreateAssetWithConfig(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	config := &chaincode.CollectionConfig{
+		AssetCollection:     "sharedAssets",
+		OrgCollectionSuffix: "Details",
+		TransientKeys: chaincode.TransientKeys{
+			AssetProperties: "props",
+			AssetValue:      "value",
+			AssetOwner:      "owner",
+			AssetDelete:     "delete",
+			AgreementDelete: "agreementDelete",
+		},
+	}
+	configBytes, err := json.Marshal(config)
+	require.NoError(t, err)
+	chaincodeStub.GetStateReturns(configBytes, nil)
+
+	// default transient key is no longer used
+	setReturnAssetPropsInTransientMap(t, chaincodeStub, &assetTransientInput{})
+	err = assetTransferCC.CreateAsset(transactionContext)
+	require.EqualError(t, err, "asset not found in the transient map input")
+
+	assetBytes, err := json.Marshal(&assetTransientInput{
+		ID:             "id1",
+		Type:           "testfulasset",
+		Color:          "gray",
+		Size:           7,
+		AppraisedValue: 500,
+	})
+	require.NoError(t, err)
+	chaincodeStub.GetTransientReturns(map[string][]byte{"props": assetBytes}, nil)
+	err = assetTransferCC.CreateAsset(transactionContext)
+	require.NoError(t, err)
+
+	calledCollection, calledId, _ := chaincodeStub.PutPrivateDataArgsForCall(0)
+	require.Equal(t, "sharedAssets", calledCollection)
+	require.Equal(t, "id1", calledId)
+	calledCollection, calledId, _ = chaincodeStub.PutPrivateDataArgsForCall(1)
+	require.Equal(t, myOrg1Msp+"Details", calledCollection)
+	require.Equal(t, "id1", calledId)
+}
@@ -0,0 +1,333 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TransferState is the state of the transfer of an asset
+type TransferState string
+
+// Transfer states. A transfer is proposed by the owner, agreed to by the buyer and then completed
+// by the owner. The owner can cancel it before completion, and it expires if not completed in time.
+const (
+	TransferProposed  TransferState = "PROPOSED"
+	TransferAgreed    TransferState = "AGREED"
+	TransferCompleted TransferState = "COMPLETED"
+	TransferCancelled TransferState = "CANCELLED"
+	TransferExpired   TransferState = "EXPIRED"
+)
+
+// transferTransitions lists the states a transfer can move to from each state.
+// Once a transfer is over, a new one can be proposed or agreed to.
+var transferTransitions = map[TransferState][]TransferState{
+	"":                {TransferProposed, TransferAgreed},
+	TransferProposed:  {TransferAgreed, TransferCancelled, TransferExpired},
+	TransferAgreed:    {TransferCompleted, TransferCancelled, TransferExpired},
+	TransferCompleted: {TransferProposed, TransferAgreed},
+	TransferCancelled: {TransferProposed, TransferAgreed},
+	TransferExpired:   {TransferProposed, TransferAgreed},
+}
+
+// transferEventNames are the names of the chaincode events emitted when a transfer enters a state
+var transferEventNames = map[TransferState]string{
+	TransferProposed:  "TransferProposed",
+	TransferAgreed:    "TransferAgreed",
+	TransferCompleted: "TransferCompleted",
+	TransferCancelled: "TransferCancelled",
+	TransferExpired:   "TransferExpired",
+}
+
+// TransferEvent is the payload of the event emitted on each transfer state change
+type TransferEvent struct {
+	ID            string        `json:"assetID"`
+	PreviousState TransferState `json:"previousState"`
+	State         TransferState `json:"state"`
+	TxID          string        `json:"txID"`
+	Timestamp     time.Time     `json:"timestamp"`
+}
+
+// isActive reports whether the transfer is in progress
+func (a *TransferAgreement) isActive() bool {
+	return a.State == TransferProposed || a.State == TransferAgreed
+}
+
+// isExpired reports whether an in progress transfer is past its expiry time
+func (a *TransferAgreement) isExpired(now time.Time) bool {
+	return a.isActive() && !a.ExpiresAt.IsZero() && !now.Before(a.ExpiresAt)
+}
+
+// ProposeTransfer is used by the owner of the asset to open a transfer that a buyer can agree to.
+// If expiresInSeconds is positive, the transfer expires if it is not completed within that time.
+func (s *SmartContract) ProposeTransfer(ctx contractapi.TransactionContextInterface, assetID string, expiresInSeconds int) error {
+
+	if len(assetID) == 0 {
+		return fmt.Errorf("assetID must be a non-empty string")
+	}
+	if expiresInSeconds < 0 {
+		return fmt.Errorf("expiresInSeconds must not be negative")
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	err := verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("ProposeTransfer cannot be performed: Error %v", err)
+	}
+
+	err = s.verifyAssetOwner(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	status, err := readTransferStatus(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if status.isExpired(now) {
+		return fmt.Errorf("transfer of %v expired at %v, call ExpireTransfer first", assetID, status.ExpiresAt)
+	}
+
+	// A new transfer starts without the timestamps of the previous one
+	status = &TransferAgreement{ID: assetID, State: status.State}
+	if expiresInSeconds > 0 {
+		status.ExpiresAt = now.Add(time.Duration(expiresInSeconds) * time.Second)
+	}
+
+	return setTransferState(ctx, status, TransferProposed, now)
+}
+
+// CancelTransfer is used by the owner of the asset to cancel a transfer that has not been completed
+func (s *SmartContract) CancelTransfer(ctx contractapi.TransactionContextInterface, assetID string) error {
+
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Verify that the client is submitting request to peer in their organization
+	err = verifyClientOrgMatchesPeerOrg(ctx)
+	if err != nil {
+		return fmt.Errorf("CancelTransfer cannot be performed: Error %v", err)
+	}
+
+	err = s.verifyAssetOwner(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	status, err := readTransferStatus(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = setTransferState(ctx, status, TransferCancelled, now)
+	if err != nil {
+		return err
+	}
+
+	return deleteTransferAgreement(ctx, config, assetID)
+}
+
+// ExpireTransfer records that a transfer was not completed before its expiry time.
+// It can be called by any channel member once the transfer has expired.
+func (s *SmartContract) ExpireTransfer(ctx contractapi.TransactionContextInterface, assetID string) error {
+
+	config, err := readConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	status, err := readTransferStatus(ctx, assetID)
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !status.isExpired(now) {
+		return fmt.Errorf("transfer of %v has not expired", assetID)
+	}
+
+	err = setTransferState(ctx, status, TransferExpired, now)
+	if err != nil {
+		return err
+	}
+
+	return deleteTransferAgreement(ctx, config, assetID)
+}
+
+// verifyAssetOwner is an internal helper function that checks that the submitting client owns the asset
+func (s *SmartContract) verifyAssetOwner(ctx contractapi.TransactionContextInterface, assetID string) error {
+	asset, err := s.ReadAsset(ctx, assetID)
+	if err != nil {
+		return fmt.Errorf("error reading asset: %v", err)
+	}
+	if asset == nil {
+		return fmt.Errorf("%v does not exist", assetID)
+	}
+
+	clientID, err := submittingClientIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	if clientID != asset.Owner {
+		return fmt.Errorf("error: submitting client identity does not own asset")
+	}
+
+	return nil
+}
+
+// readTransferStatus is an internal helper function that reads the public transfer status of an asset.
+// The status has an empty state if no transfer was ever recorded.
+func readTransferStatus(ctx contractapi.TransactionContextInterface, assetID string) (*TransferAgreement, error) {
+	transferAgreeKey, err := ctx.GetStub().CreateCompositeKey(transferAgreementObjectType, []string{assetID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	statusJSON, err := ctx.GetStub().GetState(transferAgreeKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transfer status: %v", err)
+	}
+	if statusJSON == nil {
+		return &TransferAgreement{ID: assetID}, nil
+	}
+
+	var status *TransferAgreement
+	err = json.Unmarshal(statusJSON, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+
+	return status, nil
+}
+
+// setTransferState is an internal helper function that moves the transfer to a new state, stores the
+// public transfer status next to the transfer agreement and emits the matching event
+func setTransferState(ctx contractapi.TransactionContextInterface, status *TransferAgreement, state TransferState, now time.Time) error {
+	allowed := false
+	for _, next := range transferTransitions[status.State] {
+		allowed = allowed || next == state
+	}
+	if !allowed {
+		if status.State == "" {
+			return fmt.Errorf("no transfer of %v in progress", status.ID)
+		}
+		return fmt.Errorf("transfer of %v cannot move from %v to %v", status.ID, status.State, state)
+	}
+
+	previousState := status.State
+	status.State = state
+	switch state {
+	case TransferProposed:
+		status.ProposedAt = now
+	case TransferAgreed:
+		status.AgreedAt = now
+	case TransferCompleted:
+		status.CompletedAt = now
+	case TransferCancelled:
+		status.CancelledAt = now
+	case TransferExpired:
+		status.ExpiredAt = now
+	}
+
+	return putTransferStatus(ctx, status, previousState, now)
+}
+
+// withdrawAgreement is an internal helper function that returns a transfer proposed by the owner
+// to the PROPOSED state once the buyer withdraws their agreement, so that the proposal stands
+func withdrawAgreement(ctx contractapi.TransactionContextInterface, status *TransferAgreement, now time.Time) error {
+	if status.State != TransferAgreed || status.ProposedAt.IsZero() {
+		return fmt.Errorf("transfer of %v was not proposed by the owner", status.ID)
+	}
+
+	status.State = TransferProposed
+	status.AgreedAt = time.Time{}
+
+	return putTransferStatus(ctx, status, TransferAgreed, now)
+}
+
+// putTransferStatus is an internal helper function that stores the public transfer status next to
+// the transfer agreement and emits the event of its new state
+func putTransferStatus(ctx contractapi.TransactionContextInterface, status *TransferAgreement, previousState TransferState, now time.Time) error {
+	state := status.State
+
+	// The buyer identity stays in the asset collection, only the state is public
+	publicStatus := *status
+	publicStatus.BuyerID = ""
+	statusJSON, err := json.Marshal(publicStatus)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transfer status into JSON: %v", err)
+	}
+
+	transferAgreeKey, err := ctx.GetStub().CreateCompositeKey(transferAgreementObjectType, []string{status.ID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	log.Printf("Transfer of %v moves from %v to %v", status.ID, previousState, state)
+	err = ctx.GetStub().PutState(transferAgreeKey, statusJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put transfer status: %v", err)
+	}
+
+	event := TransferEvent{
+		ID:            status.ID,
+		PreviousState: previousState,
+		State:         state,
+		TxID:          ctx.GetStub().GetTxID(),
+		Timestamp:     now,
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event into JSON: %v", err)
+	}
+
+	return ctx.GetStub().SetEvent(transferEventNames[state], eventJSON)
+}
+
+// deleteTransferAgreement is an internal helper function that removes the buyer agreement from the asset collection
+func deleteTransferAgreement(ctx contractapi.TransactionContextInterface, config *CollectionConfig, assetID string) error {
+	transferAgreeKey, err := ctx.GetStub().CreateCompositeKey(transferAgreementObjectType, []string{assetID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	return ctx.GetStub().DelPrivateData(config.AssetCollection, transferAgreeKey)
+}
+
+// getTxTimestamp is an internal helper function that returns the transaction timestamp.
+// The transaction timestamp is the same on all endorsing peers, so it is safe to use for expiry.
+func getTxTimestamp(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+
+	return ptypes.Timestamp(txTimestamp)
+}
@@ -0,0 +1,333 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+package chaincode_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+For details on generating the mocks, see comments in the file asset_transfer_test.go
+*/
+const transferAgreementKey = transferAgreementObjectType + "id1"
+
+// txTime is the transaction timestamp returned by the mocks prepared in asset_transfer_test.go
+var txTime = time.Unix(1000, 0).UTC()
+
+func setReturnTransferStatusInStub(t *testing.T, chaincodeStub *mocks.ChaincodeStub, status *chaincode.TransferAgreement) {
+	chaincodeStub.CreateCompositeKeyReturns(transferAgreementKey, nil)
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		if key != transferAgreementKey || status == nil {
+			return nil, nil
+		}
+		return json.Marshal(status)
+	}
+}
+
+func requireTransferStatusPut(t *testing.T, chaincodeStub *mocks.ChaincodeStub, state chaincode.TransferState) *chaincode.TransferAgreement {
+	calledKey, calledStatusBytes := chaincodeStub.PutStateArgsForCall(chaincodeStub.PutStateCallCount() - 1)
+	require.Equal(t, transferAgreementKey, calledKey)
+	var status chaincode.TransferAgreement
+	require.NoError(t, json.Unmarshal(calledStatusBytes, &status))
+	require.Equal(t, state, status.State)
+	require.Empty(t, status.BuyerID)
+	return &status
+}
+
+func prepOwnedAsset(t *testing.T, chaincodeStub *mocks.ChaincodeStub) {
+	setReturnPrivateDataInStub(t, chaincodeStub, &chaincode.Asset{
+		ID:    "id1",
+		Type:  "testfulasset",
+		Color: "gray",
+		Size:  7,
+		Owner: myOrg1Clientid,
+	})
+}
+
+func TestProposeTransfer(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	err := assetTransferCC.ProposeTransfer(transactionContext, "id1", -1)
+	require.EqualError(t, err, "expiresInSeconds must not be negative")
+
+	prepOwnedAsset(t, chaincodeStub)
+	setReturnTransferStatusInStub(t, chaincodeStub, nil)
+	err = assetTransferCC.ProposeTransfer(transactionContext, "id1", 60)
+	require.NoError(t, err)
+
+	status := requireTransferStatusPut(t, chaincodeStub, chaincode.TransferProposed)
+	require.Equal(t, txTime, status.ProposedAt)
+	require.Equal(t, txTime.Add(time.Minute), status.ExpiresAt)
+
+	eventName, eventBytes := chaincodeStub.SetEventArgsForCall(0)
+	require.Equal(t, "TransferProposed", eventName)
+	var event chaincode.TransferEvent
+	require.NoError(t, json.Unmarshal(eventBytes, &event))
+	require.Equal(t, chaincode.TransferEvent{ID: "id1", State: chaincode.TransferProposed, Timestamp: txTime}, event)
+
+	//transfer already in progress
+	setReturnTransferStatusInStub(t, chaincodeStub, &chaincode.TransferAgreement{ID: "id1", State: chaincode.TransferAgreed})
+	err = assetTransferCC.ProposeTransfer(transactionContext, "id1", 0)
+	require.EqualError(t, err, "transfer of id1 cannot move from AGREED to PROPOSED")
+}
+
+func TestProposeTransferByNonOwner(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := chaincode.SmartContract{}
+
+	prepOwnedAsset(t, chaincodeStub)
+	err := assetTransferCC.ProposeTransfer(transactionContext, "id1", 0)
+	require.EqualError(t, err, "error: submitting client identity does not own asset")
+}
+
+func TestAgreeToExpiredTransfer(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := chaincode.SmartContract{}
+
+	setReturnAssetPrivateDetailsInTransientMap(t, chaincodeStub, &chaincode.AssetPrivateDetails{
+		ID:             "id1",
+		AppraisedValue: 500,
+	})
+	prepOwnedAsset(t, chaincodeStub)
+	setReturnTransferStatusInStub(t, chaincodeStub, &chaincode.TransferAgreement{
+		ID:        "id1",
+		State:     chaincode.TransferProposed,
+		ExpiresAt: txTime,
+	})
+
+	err := assetTransferCC.AgreeToTransfer(transactionContext)
+	require.EqualError(t, err, "transfer of id1 expired at "+txTime.String())
+}
+
+func TestCancelTransfer(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg1()
+	assetTransferCC := chaincode.SmartContract{}
+
+	prepOwnedAsset(t, chaincodeStub)
+	setReturnTransferStatusInStub(t, chaincodeStub, nil)
+	err := assetTransferCC.CancelTransfer(transactionContext, "id1")
+	require.EqualError(t, err, "no transfer of id1 in progress")
+
+	setReturnTransferStatusInStub(t, chaincodeStub, &chaincode.TransferAgreement{ID: "id1", State: chaincode.TransferCompleted})
+	err = assetTransferCC.CancelTransfer(transactionContext, "id1")
+	require.EqualError(t, err, "transfer of id1 cannot move from COMPLETED to CANCELLED")
+
+	setReturnTransferStatusInStub(t, chaincodeStub, &chaincode.TransferAgreement{ID: "id1", State: chaincode.TransferAgreed})
+	err = assetTransferCC.CancelTransfer(transactionContext, "id1")
+	require.NoError(t, err)
+
+	status := requireTransferStatusPut(t, chaincodeStub, chaincode.TransferCancelled)
+	require.Equal(t, txTime, status.CancelledAt)
+	eventName, _ := chaincodeStub.SetEventArgsForCall(0)
+	require.Equal(t, "TransferCancelled", eventName)
+
+	//buyer agreement is removed
+	calledCollection, calledId := chaincodeStub.DelPrivateDataArgsForCall(0)
+	require.Equal(t, assetCollectionName, calledCollection)
+	require.Equal(t, transferAgreementKey, calledId)
+}
+
+func TestExpireTransfer(t *testing.T) {
+	transactionContext, chaincodeStub := prepMocksAsOrg2()
+	assetTransferCC := chaincode.SmartContract{}
+
+	setReturnTransferStatusInStub(t, chaincodeStub, &chaincode.TransferAgreement{
+		ID:        "id1",
+		State:     chaincode.TransferAgreed,
+		ExpiresAt: txTime.Add(time.Second),
+	})
+	err := assetTransferCC.ExpireTransfer(transactionContext, "id1")
+	require.EqualError(t, err, "transfer of id1 has not expired")
+
+	setReturnTransferStatusInStub(t, chaincodeStub, &chaincode.TransferAgreement{
+		ID:        "id1",
+		State:     chaincode.TransferAgreed,
+		ExpiresAt: txTime,
+	})
+	err = assetTransferCC.ExpireTransfer(transactionContext, "id1")
+	require.NoError(t, err)
+
+	status := requireTransferStatusPut(t, chaincodeStub, chaincode.TransferExpired)
+	require.Equal(t, txTime, status.ExpiredAt)
+	eventName, _ := chaincodeStub.SetEventArgsForCall(0)
+	require.Equal(t, "TransferExpired", eventName)
+}
+
+// ledger keeps the world state and the private data written through the mock stubs of several
+// transactions, so that a test can run a transfer from one organization to the other
+type ledger struct {
+	state       map[string][]byte
+	privateData map[string]map[string][]byte
+	now         time.Time
+}
+
+func newLedger() *ledger {
+	return &ledger{
+		state:       map[string][]byte{},
+		privateData: map[string]map[string][]byte{},
+		now:         txTime,
+	}
+}
+
+// as returns a transaction context of a client of the given organization, backed by the ledger
+func (l *ledger) as(orgMSP, clientID string) *mocks.TransactionContext {
+	transactionContext, chaincodeStub := prepMocks(orgMSP, clientID)
+	chaincodeStub.GetTxTimestampStub = func() (*timestamp.Timestamp, error) {
+		return ptypes.TimestampProto(l.now)
+	}
+	chaincodeStub.CreateCompositeKeyStub = func(objectType string, attributes []string) (string, error) {
+		return objectType + strings.Join(attributes, ""), nil
+	}
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		return l.state[key], nil
+	}
+	chaincodeStub.PutStateStub = func(key string, value []byte) error {
+		l.state[key] = value
+		return nil
+	}
+	chaincodeStub.GetPrivateDataStub = func(collection, key string) ([]byte, error) {
+		return l.privateData[collection][key], nil
+	}
+	chaincodeStub.GetPrivateDataHashStub = func(collection, key string) ([]byte, error) {
+		value, ok := l.privateData[collection][key]
+		if !ok {
+			return nil, nil
+		}
+		hash := sha256.Sum256(value)
+		return hash[:], nil
+	}
+	chaincodeStub.PutPrivateDataStub = func(collection, key string, value []byte) error {
+		if l.privateData[collection] == nil {
+			l.privateData[collection] = map[string][]byte{}
+		}
+		l.privateData[collection][key] = value
+		return nil
+	}
+	chaincodeStub.DelPrivateDataStub = func(collection, key string) error {
+		delete(l.privateData[collection], key)
+		return nil
+	}
+	return transactionContext
+}
+
+func TestTransferAgainAfterExpiry(t *testing.T) {
+	ledger := newLedger()
+	assetTransferCC := chaincode.SmartContract{}
+	org1 := func() *mocks.TransactionContext { return ledger.as(myOrg1Msp, myOrg1Clientid) }
+	org2 := func() *mocks.TransactionContext { return ledger.as(myOrg2Msp, myOrg2Clientid) }
+
+	transactionContext := org1()
+	setReturnAssetPropsInTransientMap(t, transactionContext.GetStub().(*mocks.ChaincodeStub), &assetTransientInput{
+		ID:             "id1",
+		Type:           "testfulasset",
+		Color:          "gray",
+		Size:           7,
+		AppraisedValue: 500,
+	})
+	require.NoError(t, assetTransferCC.CreateAsset(transactionContext))
+
+	//first sale, from org1 to org2, expires after a minute
+	require.NoError(t, assetTransferCC.ProposeTransfer(org1(), "id1", 60))
+
+	transactionContext = org2()
+	setReturnAssetPrivateDetailsInTransientMap(t, transactionContext.GetStub().(*mocks.ChaincodeStub), &chaincode.AssetPrivateDetails{
+		ID:             "id1",
+		AppraisedValue: 500,
+	})
+	require.NoError(t, assetTransferCC.AgreeToTransfer(transactionContext))
+
+	transactionContext = org1()
+	setReturnAssetOwnerInTransientMap(t, transactionContext.GetStub().(*mocks.ChaincodeStub), &assetTransferTransientInput{
+		ID:       "id1",
+		BuyerMSP: myOrg2Msp,
+	})
+	require.NoError(t, assetTransferCC.TransferAsset(transactionContext))
+
+	//second sale, from org2 back to org1, once the expiry of the first sale has passed
+	ledger.now = txTime.Add(2 * time.Minute)
+
+	transactionContext = org1()
+	setReturnAssetPrivateDetailsInTransientMap(t, transactionContext.GetStub().(*mocks.ChaincodeStub), &chaincode.AssetPrivateDetails{
+		ID:             "id1",
+		AppraisedValue: 500,
+	})
+	require.NoError(t, assetTransferCC.AgreeToTransfer(transactionContext))
+
+	transactionContext = org2()
+	setReturnAssetOwnerInTransientMap(t, transactionContext.GetStub().(*mocks.ChaincodeStub), &assetTransferTransientInput{
+		ID:       "id1",
+		BuyerMSP: myOrg1Msp,
+	})
+	require.NoError(t, assetTransferCC.TransferAsset(transactionContext))
+
+	asset, err := assetTransferCC.ReadAsset(org1(), "id1")
+	require.NoError(t, err)
+	require.Equal(t, myOrg1Clientid, asset.Owner)
+
+	var status chaincode.TransferAgreement
+	require.NoError(t, json.Unmarshal(ledger.state[transferAgreementKey], &status))
+	require.Equal(t, chaincode.TransferCompleted, status.State)
+	require.True(t, status.ExpiresAt.IsZero())
+	require.True(t, status.ProposedAt.IsZero())
+	require.Equal(t, ledger.now, status.AgreedAt)
+	require.Equal(t, ledger.now, status.CompletedAt)
+}
+
+func TestDeleteTransferAgreement(t *testing.T) {
+	ledger := newLedger()
+	assetTransferCC := chaincode.SmartContract{}
+	ledger.privateData[assetCollectionName] = map[string][]byte{transferAgreementKey: []byte(myOrg2Clientid)}
+	agreementDeleteMap := map[string][]byte{"agreement_delete": []byte(`{"assetID":"id1"}`)}
+
+	//the owner cannot withdraw the agreement of the buyer
+	transactionContext := ledger.as(myOrg1Msp, myOrg1Clientid)
+	transactionContext.GetStub().(*mocks.ChaincodeStub).GetTransientReturns(agreementDeleteMap, nil)
+	err := assetTransferCC.DeleteTranferAgreement(transactionContext)
+	require.EqualError(t, err, "error: submitting client identity did not agree to the transfer of id1")
+
+	//a transfer proposed by the owner stays proposed when the buyer withdraws
+	statusJSON, err := json.Marshal(chaincode.TransferAgreement{
+		ID:         "id1",
+		State:      chaincode.TransferAgreed,
+		ProposedAt: txTime,
+		AgreedAt:   txTime,
+	})
+	require.NoError(t, err)
+	ledger.state[transferAgreementKey] = statusJSON
+
+	transactionContext = ledger.as(myOrg2Msp, myOrg2Clientid)
+	chaincodeStub := transactionContext.GetStub().(*mocks.ChaincodeStub)
+	chaincodeStub.GetTransientReturns(agreementDeleteMap, nil)
+	require.NoError(t, assetTransferCC.DeleteTranferAgreement(transactionContext))
+
+	status := requireTransferStatusPut(t, chaincodeStub, chaincode.TransferProposed)
+	require.Equal(t, txTime, status.ProposedAt)
+	require.True(t, status.AgreedAt.IsZero())
+	require.NotContains(t, ledger.privateData[assetCollectionName], transferAgreementKey)
+
+	//a transfer started by the buyer is cancelled
+	ledger.privateData[assetCollectionName][transferAgreementKey] = []byte(myOrg2Clientid)
+	statusJSON, err = json.Marshal(chaincode.TransferAgreement{ID: "id1", State: chaincode.TransferAgreed, AgreedAt: txTime})
+	require.NoError(t, err)
+	ledger.state[transferAgreementKey] = statusJSON
+
+	transactionContext = ledger.as(myOrg2Msp, myOrg2Clientid)
+	chaincodeStub = transactionContext.GetStub().(*mocks.ChaincodeStub)
+	chaincodeStub.GetTransientReturns(agreementDeleteMap, nil)
+	require.NoError(t, assetTransferCC.DeleteTranferAgreement(transactionContext))
+	requireTransferStatusPut(t, chaincodeStub, chaincode.TransferCancelled)
+}
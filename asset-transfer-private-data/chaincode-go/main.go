@@ -7,12 +7,11 @@ package main
 import (
 	"log"
 
-	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/hyperledger/fabric-samples/asset-transfer-private-data/chaincode-go/chaincode"
 )
 
 func main() {
-	assetChaincode, err := contractapi.NewChaincode(&chaincode.SmartContract{})
+	assetChaincode, err := chaincode.NewChaincode()
 	if err != nil {
 		log.Panicf("Error creating asset-transfer-private-data chaincode: %v", err)
 	}
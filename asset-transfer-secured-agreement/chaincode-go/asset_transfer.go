@@ -23,6 +23,9 @@ const (
 	typeAssetBid         = "B"
 	typeAssetSaleReceipt = "SR"
 	typeAssetBuyReceipt  = "BR"
+	// Sale receipts are keyed by asset ID and then transaction ID under typeAssetSaleReceiptByAsset,
+	// like buy receipts. The receipts written before are keyed by transaction ID first, under typeAssetSaleReceipt.
+	typeAssetSaleReceiptByAsset = "SRA"
 )
 
 type SmartContract struct {
@@ -38,8 +41,8 @@ type Asset struct {
 }
 
 type receipt struct {
-	price     int
-	timestamp time.Time
+	Price     int       `json:"price"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // CreateAsset creates an asset and sets it as owned by the client's org
@@ -439,8 +442,8 @@ func transferAssetState(ctx contractapi.TransactionContextInterface, asset *Asse
 		return err
 	}
 	assetReceipt := receipt{
-		price:     price,
-		timestamp: timestamp,
+		Price:     price,
+		Timestamp: timestamp,
 	}
 	receipt, err := json.Marshal(assetReceipt)
 	if err != nil {
@@ -452,7 +455,7 @@ func transferAssetState(ctx contractapi.TransactionContextInterface, asset *Asse
 		return fmt.Errorf("failed to put private asset receipt for buyer: %v", err)
 	}
 
-	receiptSaleKey, err := ctx.GetStub().CreateCompositeKey(typeAssetSaleReceipt, []string{ctx.GetStub().GetTxID(), asset.ID})
+	receiptSaleKey, err := ctx.GetStub().CreateCompositeKey(typeAssetSaleReceiptByAsset, []string{asset.ID, ctx.GetStub().GetTxID()})
 	if err != nil {
 		return fmt.Errorf("failed to create composite key for receipt: %v", err)
 	}
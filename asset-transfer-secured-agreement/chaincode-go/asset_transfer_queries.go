@@ -26,6 +26,25 @@ type Agreement struct {
 	TradeID string `json:"trade_id"`
 }
 
+// AssetReceipt structure used for returning sale and buy receipts from an org's collection
+type AssetReceipt struct {
+	ReceiptType string    `json:"receiptType"` // typeAssetSaleReceipt or typeAssetBuyReceipt
+	AssetID     string    `json:"assetID"`
+	TxId        string    `json:"txId"`
+	Price       int       `json:"price"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// TransferCertificate is an unsigned summary of a transfer, built from the public ledger. It carries
+// no signature or endorsement, so it proves nothing by itself: a verifier checks every field against
+// the ledger from a peer it trusts, PriceHash being the on-chain hash of the buyer's private receipt.
+type TransferCertificate struct {
+	Asset     *Asset    `json:"asset"`
+	TxId      string    `json:"txId"`
+	Timestamp time.Time `json:"timestamp"`
+	PriceHash string    `json:"priceHash"`
+}
+
 // ReadAsset returns the public asset data
 func (s *SmartContract) ReadAsset(ctx contractapi.TransactionContextInterface, assetID string) (*Asset, error) {
 	// Since only public data is accessed in this function, no access control is required
@@ -139,6 +158,140 @@ func queryAgreementsByType(ctx contractapi.TransactionContextInterface, agreeTyp
 	return agreements, nil
 }
 
+// QueryAssetReceipts returns the sale and buy receipts of an asset from the client org's collection
+func (s *SmartContract) QueryAssetReceipts(ctx contractapi.TransactionContextInterface, assetID string) ([]AssetReceipt, error) {
+	collection, err := getClientImplicitCollectionName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	saleReceipts, err := queryReceipts(ctx, collection, typeAssetSaleReceiptByAsset, []string{assetID}, typeAssetSaleReceipt, true, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sale receipts written under typeAssetSaleReceipt are keyed by transaction ID first, so they
+	// cannot be looked up by a partial assetID key
+	legacySaleReceipts, err := queryReceipts(ctx, collection, typeAssetSaleReceipt, []string{}, typeAssetSaleReceipt, false, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	buyReceipts, err := queryReceipts(ctx, collection, typeAssetBuyReceipt, []string{assetID}, typeAssetBuyReceipt, true, assetID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts := append(saleReceipts, legacySaleReceipts...)
+	return append(receipts, buyReceipts...), nil
+}
+
+// queryReceipts returns the receipts of an asset among the receipts of objectType matching the
+// partial key attributes. The receipt keys hold the asset ID and the transaction ID, in this order
+// if assetIDFirst is set, in the reverse order otherwise.
+func queryReceipts(ctx contractapi.TransactionContextInterface, collection string, objectType string, attributes []string, receiptType string, assetIDFirst bool, assetID string) ([]AssetReceipt, error) {
+	receiptsIterator, err := ctx.GetStub().GetPrivateDataByPartialCompositeKey(collection, objectType, attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from private data collection: %v", err)
+	}
+	defer receiptsIterator.Close()
+
+	var receipts []AssetReceipt
+	for receiptsIterator.HasNext() {
+		resp, err := receiptsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(resp.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split composite key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			return nil, fmt.Errorf("unexpected receipt key %s", resp.Key)
+		}
+
+		assetReceipt := AssetReceipt{ReceiptType: receiptType}
+		if assetIDFirst {
+			assetReceipt.AssetID, assetReceipt.TxId = keyParts[0], keyParts[1]
+		} else {
+			assetReceipt.TxId, assetReceipt.AssetID = keyParts[0], keyParts[1]
+		}
+		if assetReceipt.AssetID != assetID {
+			continue
+		}
+
+		var r receipt
+		err = json.Unmarshal(resp.Value, &r)
+		if err != nil {
+			return nil, err
+		}
+		assetReceipt.Price = r.Price
+		assetReceipt.Timestamp = r.Timestamp
+
+		receipts = append(receipts, assetReceipt)
+	}
+
+	return receipts, nil
+}
+
+// GetTransferCertificate returns the unsigned summary of the transfer of an asset in a given transaction.
+// The certificate is built from public data only, so it can be requested from any org's peer.
+// It is not signed: to rely on it, query it from a peer of your own org or check it against the ledger.
+func (s *SmartContract) GetTransferCertificate(ctx contractapi.TransactionContextInterface, assetID string, txID string) (*TransferCertificate, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(assetID)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		response, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if response.TxId != txID {
+			continue
+		}
+		if response.IsDelete {
+			return nil, fmt.Errorf("transaction %s deleted asset %s", txID, assetID)
+		}
+
+		var asset *Asset
+		err = json.Unmarshal(response.Value, &asset)
+		if err != nil {
+			return nil, err
+		}
+
+		timestamp, err := ptypes.Timestamp(response.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		// The buy receipt is written to the new owner's collection by transferAssetState
+		receiptBuyKey, err := ctx.GetStub().CreateCompositeKey(typeAssetBuyReceipt, []string{assetID, txID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create composite key for receipt: %v", err)
+		}
+		receiptHash, err := ctx.GetStub().GetPrivateDataHash(buildCollectionName(asset.OwnerOrg), receiptBuyKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read receipt hash from buyer's collection: %v", err)
+		}
+		if receiptHash == nil {
+			return nil, fmt.Errorf("transaction %s is not a transfer of asset %s", txID, assetID)
+		}
+
+		return &TransferCertificate{
+			Asset:     asset,
+			TxId:      txID,
+			Timestamp: timestamp,
+			PriceHash: fmt.Sprintf("%x", receiptHash),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("transaction %s not found in history of asset %s", txID, assetID)
+}
+
 // QueryAssetHistory returns the chain of custody for a asset since issuance
 func (s *SmartContract) QueryAssetHistory(ctx contractapi.TransactionContextInterface, assetID string) ([]QueryResult, error) {
 	resultsIterator, err := ctx.GetStub().GetHistoryForKey(assetID)
@@ -7,18 +7,13 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"strconv"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-external/serverconfig"
 )
 
-type ServerConfig struct {
-	CCID    string
-	Address string
-}
-
 // SmartContract provides functions for managing a car
 type SmartContract struct {
 	contractapi.Contract
@@ -145,9 +140,10 @@ func (s *SmartContract) ChangeCarOwner(ctx contractapi.TransactionContextInterfa
 
 func main() {
 	// See chaincode.env.example
-	config := ServerConfig{
-		CCID:    os.Getenv("CHAINCODE_ID"),
-		Address: os.Getenv("CHAINCODE_SERVER_ADDRESS"),
+	config, err := serverconfig.FromEnv()
+	if err != nil {
+		fmt.Printf("Error loading fabcar chaincode server configuration: %s", err.Error())
+		return
 	}
 
 	chaincode, err := contractapi.NewChaincode(new(SmartContract))
@@ -158,12 +154,11 @@ func main() {
 	}
 
 	server := &shim.ChaincodeServer{
-		CCID:    config.CCID,
-		Address: config.Address,
-		CC:      chaincode,
-		TLSProps: shim.TLSProperties{
-			Disabled: true,
-		},
+		CCID:     config.CCID,
+		Address:  config.Address,
+		CC:       chaincode,
+		TLSProps: config.TLSProps,
+		KaOpts:   &config.Keepalive,
 	}
 
 	if err := server.Start(); err != nil {
@@ -5,4 +5,8 @@ go 1.13
 require (
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200424173110-d7076418f212
 	github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-external/serverconfig v0.0.0
 )
+
+// The external chaincode server configuration module is shared with asset-transfer-basic
+replace github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-external/serverconfig => ../../../asset-transfer-basic/chaincode-external/serverconfig
@@ -2,4 +2,7 @@ module fabcar
 
 go 1.14
 
-require github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
+require github.com/hyperledger/fabric-samples/test-application/go v0.0.0
+
+// The connection and wallet helpers are shared by the Go sample applications
+replace github.com/hyperledger/fabric-samples/test-application/go => ../../test-application/go
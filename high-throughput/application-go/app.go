@@ -9,8 +9,10 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	f "github.com/hyperledger/fabric-samples/high-throughput/application-go/functions"
+	"github.com/hyperledger/fabric-samples/test-application/go/apputil"
 )
 
 func main() {
@@ -32,39 +34,91 @@ func main() {
 		sign = os.Args[4]
 	}
 
+	// All the functions use the same connection
+	connection, err := apputil.Connect(apputil.WithChaincode("bigdatacc"))
+	if err != nil {
+		log.Fatalf("error: %v", err)
+	}
+	defer connection.Close()
+	contract := connection.Contract
+
+	// Transactions failing with an MVCC read conflict or an endorsement mismatch are retried, see
+	// apputil.RetryPolicy. The delta updates are not idempotent, so timeouts are not retried.
+	retryPolicy := apputil.DefaultRetryPolicy
+	retryPolicy.OnRetry = func(name string, attempt int, class apputil.ErrorClass, err error, backoff time.Duration) {
+		log.Printf("attempt %d of %s failed with %s, retrying in %v: %v", attempt, name, class, backoff, err)
+	}
+
 	// Handle different functions
 	if function == "update" {
-		result, err := f.Update(function, variableName, change, sign)
+		result, err := f.Update(contract, retryPolicy, function, variableName, change, sign)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
 		log.Println("Value of variable", string(variableName), ": ", string(result))
 
 	} else if function == "delete" || function == "prune" || function == "delstandard" {
-		result, err := f.DeletePrune(function, variableName)
+		result, err := f.DeletePrune(contract, retryPolicy, function, variableName)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
 		log.Println(string(result))
 	} else if function == "get" || function == "getstandard" {
-		result, err := f.Query(function, variableName)
+		result, err := f.Query(contract, function, variableName)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
 		log.Println("Value of variable", string(variableName), ": ", string(result))
 	} else if function == "manyUpdates" {
 		log.Println("submitting 1000 concurrent updates...")
-		result, err := f.ManyUpdates("update", variableName, change, sign)
+		// The concurrent updates conflict with each other, so retries are only reported in the summary
+		result, err := f.ManyUpdates(contract, apputil.DefaultRetryPolicy, "update", variableName, change, sign)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
-		log.Println("Final value of variable", string(variableName), ": ", string(result))
+		failed := reportOutcomes(result.Outcomes)
+		log.Println("Final value of variable", string(variableName), ": ", string(result.Value))
+		if failed {
+			os.Exit(1)
+		}
 	} else if function == "manyUpdatesTraditional" {
 		log.Println("submitting 1000 concurrent updates...")
-		result, err := f.ManyUpdates("putstandard", variableName, change, sign)
+		// The concurrent updates conflict with each other, so retries are only reported in the summary
+		result, err := f.ManyUpdates(contract, apputil.DefaultRetryPolicy, "putstandard", variableName, change, sign)
 		if err != nil {
 			log.Fatalf("error: %v", err)
 		}
-		log.Println("Final value of variable", string(variableName), ": ", string(result))
+		failed := reportOutcomes(result.Outcomes)
+		log.Println("Final value of variable", string(variableName), ": ", string(result.Value))
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
+
+// reportOutcomes logs the updates that failed, and a summary of the outcomes of all the
+// updates. It returns whether any update failed.
+func reportOutcomes(outcomes []*apputil.Outcome) bool {
+	committed, retries := 0, 0
+	failures := map[apputil.ErrorClass]int{}
+	for i, outcome := range outcomes {
+		retries += outcome.Attempts - 1
+		if outcome.Err == nil {
+			committed++
+			continue
+		}
+		failures[outcome.Class]++
+		if outcome.Class == apputil.Timeout {
+			log.Printf("update %d timed out after %d attempts and may have been committed: %v", i, outcome.Attempts, outcome.Err)
+			continue
+		}
+		log.Printf("update %d failed after %d attempts with %s: %v", i, outcome.Attempts, outcome.Class, outcome.Err)
 	}
+
+	log.Printf("%d of %d updates committed, %d retries", committed, len(outcomes), retries)
+	for class, count := range failures {
+		log.Printf("%d updates failed with %s", count, class)
+	}
+
+	return committed < len(outcomes)
 }
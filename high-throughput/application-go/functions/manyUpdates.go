@@ -8,79 +8,43 @@ package functions
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
 
-	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-samples/test-application/go/apputil"
 	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
 )
 
-// ManyUpdates allows you to push many cuncurrent updates to a variable
-func ManyUpdates(function, variableName, change, sign string) ([]byte, error) {
+// updateCount is the number of concurrent updates submitted by ManyUpdates
+const updateCount = 1000
 
-	err := os.Setenv("DISCOVERY_AS_LOCALHOST", "true")
-	if err != nil {
-		return nil, fmt.Errorf("error setting DISCOVERY_AS_LOCALHOST environemnt variable: %v", err)
-	}
-
-	wallet, err := gateway.NewFileSystemWallet("wallet")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create wallet: %v", err)
-	}
-
-	if !wallet.Exists("appUser") {
-		err := populateWallet(wallet)
-		if err != nil {
-			return nil, fmt.Errorf("failed to populate wallet contents: %v", err)
-		}
-	}
-
-	ccpPath := filepath.Join(
-		"..",
-		"..",
-		"test-network",
-		"organizations",
-		"peerOrganizations",
-		"org1.example.com",
-		"connection-org1.yaml",
-	)
-
-	gw, err := gateway.Connect(
-		gateway.WithConfig(config.FromFile(filepath.Clean(ccpPath))),
-		gateway.WithIdentity(wallet, "appUser"),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to gateway: %v", err)
-	}
-	defer gw.Close()
-
-	network, err := gw.GetNetwork("mychannel")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get network: %v", err)
-	}
-
-	contract := network.GetContract("bigdatacc")
+// ManyUpdatesResult is the result of ManyUpdates: the final value of the variable, and the
+// outcome of each update
+type ManyUpdatesResult struct {
+	Value    []byte
+	Outcomes []*apputil.Outcome
+}
 
+// ManyUpdates allows you to push many cuncurrent updates to a variable. Updates that fail with
+// a retryable error, such as an MVCC read conflict, are retried with the retry policy.
+func ManyUpdates(contract *gateway.Contract, retryPolicy apputil.RetryPolicy, function, variableName, change, sign string) (*ManyUpdatesResult, error) {
 	var wg sync.WaitGroup
 
-	for i := 0; i < 1000; i++ {
+	result := &ManyUpdatesResult{Outcomes: make([]*apputil.Outcome, updateCount)}
+	for i := range result.Outcomes {
 		wg.Add(1)
-		go func() ([]byte, error) {
+		go func(i int) {
 			defer wg.Done()
-			result, err := contract.SubmitTransaction(function, variableName, change, sign)
-			if err != nil {
-				return result, fmt.Errorf("failed to evaluate transaction: %v", err)
-			}
-			return result, nil
-		}()
+			result.Outcomes[i] = retryPolicy.Submit(contract, function, variableName, change, sign)
+		}(i)
 	}
 
 	wg.Wait()
 
-	result, err := contract.EvaluateTransaction("get", variableName)
+	value, err := contract.EvaluateTransaction("get", variableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to evaluate transaction: %v", err)
+		return result, fmt.Errorf("failed to evaluate transaction: %v", err)
 	}
-	return result, err
+	result.Value = value
+
+	return result, nil
 }
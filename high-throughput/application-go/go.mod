@@ -3,6 +3,10 @@ module github.com/hyperledger/fabric-samples/high-throughput/application-go
 go 1.14
 
 require (
+	github.com/hyperledger/fabric-samples/test-application/go v0.0.0
 	github.com/hyperledger/fabric-sdk-go v1.0.0-rc1
 	golang.org/x/tools v0.1.0 // indirect
 )
+
+// The connection and wallet helpers are shared by the Go sample applications
+replace github.com/hyperledger/fabric-samples/test-application/go => ../../test-application/go
@@ -0,0 +1,234 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package apputil connects the Go sample applications to a contract on the test network.
+// It loads a connection profile, adds the application identity to a file system wallet
+// when it is missing, and returns the contract of a chaincode on a channel.
+package apputil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/gateway"
+)
+
+// testNetworkOrg1 is the Org1 directory of the test network, relative to the directories of
+// the sample applications
+var testNetworkOrg1 = filepath.Join("..", "..", "test-network", "organizations", "peerOrganizations", "org1.example.com")
+
+// Defaults of the connection options, which connect User1 of Org1 to the test network
+var (
+	DefaultConnectionProfile = filepath.Join(testNetworkOrg1, "connection-org1.yaml")
+	DefaultMSPPath           = filepath.Join(testNetworkOrg1, "users", "User1@org1.example.com", "msp")
+)
+
+const (
+	DefaultChannel    = "mychannel"
+	DefaultWalletPath = "wallet"
+	DefaultIdentity   = "appUser"
+	DefaultMSPID      = "Org1MSP"
+)
+
+type options struct {
+	connectionProfile    string
+	walletPath           string
+	identity             string
+	mspID                string
+	mspPath              string
+	certPath             string
+	keyPath              string
+	channel              string
+	chaincode            string
+	discoveryAsLocalhost bool
+}
+
+// Option configures Connect
+type Option func(*options)
+
+// WithConnectionProfile sets the path of the connection profile
+func WithConnectionProfile(path string) Option {
+	return func(o *options) {
+		o.connectionProfile = path
+	}
+}
+
+// WithWallet sets the directory of the file system wallet
+func WithWallet(path string) Option {
+	return func(o *options) {
+		o.walletPath = path
+	}
+}
+
+// WithIdentity sets the label of the identity in the wallet, and its MSP ID
+func WithIdentity(label string, mspID string) Option {
+	return func(o *options) {
+		o.identity = label
+		o.mspID = mspID
+	}
+}
+
+// WithMSPDirectory sets the MSP directory the identity is read from when it is not in the
+// wallet. The certificate is read from signcerts/cert.pem, and the private key is the single
+// file of the keystore directory.
+func WithMSPDirectory(path string) Option {
+	return func(o *options) {
+		o.mspPath = path
+		o.certPath = ""
+		o.keyPath = ""
+	}
+}
+
+// WithCertificateAndKey sets the PEM files the identity is read from when it is not in the
+// wallet, for identities whose private key is a file rather than in an HSM
+func WithCertificateAndKey(certPath string, keyPath string) Option {
+	return func(o *options) {
+		o.mspPath = ""
+		o.certPath = certPath
+		o.keyPath = keyPath
+	}
+}
+
+// WithChannel sets the channel of the contract
+func WithChannel(name string) Option {
+	return func(o *options) {
+		o.channel = name
+	}
+}
+
+// WithChaincode sets the name of the chaincode of the contract
+func WithChaincode(name string) Option {
+	return func(o *options) {
+		o.chaincode = name
+	}
+}
+
+// WithDiscoveryAsLocalhost sets whether the addresses of the peers found by service discovery
+// are mapped to localhost, which is needed to reach the test network from the host. It is
+// enabled by default.
+// The SDK only reads this setting from the environment, so Connect sets the DISCOVERY_AS_LOCALHOST
+// environment variable of the process with os.Setenv. The variable is read when a gateway
+// connects, so it also applies to the gateways the process connects afterwards without Connect,
+// and concurrent calls to Connect with different values race with each other.
+func WithDiscoveryAsLocalhost(enabled bool) Option {
+	return func(o *options) {
+		o.discoveryAsLocalhost = enabled
+	}
+}
+
+// Connection is a gateway connection to a contract. It must be closed once it is not needed.
+type Connection struct {
+	Gateway  *gateway.Gateway
+	Network  *gateway.Network
+	Contract *gateway.Contract
+}
+
+// Close closes the gateway connection
+func (c *Connection) Close() {
+	c.Gateway.Close()
+}
+
+// Connect connects to the contract of a chaincode. The chaincode name is required, the other
+// options default to User1 of Org1 on the mychannel channel of the test network.
+func Connect(opts ...Option) (*Connection, error) {
+	o := &options{
+		connectionProfile:    DefaultConnectionProfile,
+		walletPath:           DefaultWalletPath,
+		identity:             DefaultIdentity,
+		mspID:                DefaultMSPID,
+		mspPath:              DefaultMSPPath,
+		channel:              DefaultChannel,
+		discoveryAsLocalhost: true,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.chaincode == "" {
+		return nil, fmt.Errorf("the chaincode name must be set")
+	}
+
+	// The SDK only reads this setting from the environment
+	err := os.Setenv("DISCOVERY_AS_LOCALHOST", fmt.Sprint(o.discoveryAsLocalhost))
+	if err != nil {
+		return nil, fmt.Errorf("error setting DISCOVERY_AS_LOCALHOST environment variable: %v", err)
+	}
+
+	wallet, err := gateway.NewFileSystemWallet(o.walletPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %v", err)
+	}
+
+	if !wallet.Exists(o.identity) {
+		if o.mspPath != "" {
+			err = PopulateWalletFromMSP(wallet, o.identity, o.mspID, o.mspPath)
+		} else {
+			err = PopulateWallet(wallet, o.identity, o.mspID, o.certPath, o.keyPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to populate wallet contents: %v", err)
+		}
+	}
+
+	gw, err := gateway.Connect(
+		gateway.WithConfig(config.FromFile(filepath.Clean(o.connectionProfile))),
+		gateway.WithIdentity(wallet, o.identity),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to gateway: %v", err)
+	}
+
+	network, err := gw.GetNetwork(o.channel)
+	if err != nil {
+		gw.Close()
+		return nil, fmt.Errorf("failed to get network: %v", err)
+	}
+
+	return &Connection{
+		Gateway:  gw,
+		Network:  network,
+		Contract: network.GetContract(o.chaincode),
+	}, nil
+}
+
+// PopulateWalletFromMSP adds the identity of an MSP directory to the wallet, with the given label
+func PopulateWalletFromMSP(wallet *gateway.Wallet, label string, mspID string, mspPath string) error {
+	certPath := filepath.Join(mspPath, "signcerts", "cert.pem")
+
+	keyDir := filepath.Join(mspPath, "keystore")
+	// there's a single file in this dir containing the private key
+	files, err := ioutil.ReadDir(keyDir)
+	if err != nil {
+		return err
+	}
+	if len(files) != 1 {
+		return fmt.Errorf("keystore folder should have contain one file")
+	}
+	keyPath := filepath.Join(keyDir, files[0].Name())
+
+	return PopulateWallet(wallet, label, mspID, certPath, keyPath)
+}
+
+// PopulateWallet adds the identity of a PEM certificate and private key to the wallet, with
+// the given label
+func PopulateWallet(wallet *gateway.Wallet, label string, mspID string, certPath string, keyPath string) error {
+	// read the certificate pem
+	cert, err := ioutil.ReadFile(filepath.Clean(certPath))
+	if err != nil {
+		return err
+	}
+
+	key, err := ioutil.ReadFile(filepath.Clean(keyPath))
+	if err != nil {
+		return err
+	}
+
+	identity := gateway.NewX509Identity(mspID, string(cert), string(key))
+
+	return wallet.Put(label, identity)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package apputil
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorClass is the kind of failure of a transaction, which tells whether it can be retried
+type ErrorClass int
+
+const (
+	// UnknownError is a failure that is not classified, it is not retried
+	UnknownError ErrorClass = iota
+	// MVCCConflict is a transaction invalidated at commit because another transaction changed
+	// the keys it read (MVCC_READ_CONFLICT or PHANTOM_READ_CONFLICT). It is retried.
+	MVCCConflict
+	// EndorsementMismatch is a proposal whose endorsements differ between peers, usually because
+	// the peers were at different heights. It is retried.
+	EndorsementMismatch
+	// Timeout is a proposal or commit that timed out. The transaction may still be committed,
+	// so it is only retried by policies with RetryTimeouts set.
+	Timeout
+	// ChaincodeError is an error returned by the chaincode. It is not retried, since the
+	// chaincode would most likely return it again.
+	ChaincodeError
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case MVCCConflict:
+		return "MVCC conflict"
+	case EndorsementMismatch:
+		return "endorsement mismatch"
+	case Timeout:
+		return "timeout"
+	case ChaincodeError:
+		return "chaincode error"
+	default:
+		return "unknown error"
+	}
+}
+
+// Retryable returns whether a transaction that failed with this class of error can be retried.
+// Timeouts can only be retried if the transaction can safely be committed twice.
+func (c ErrorClass) Retryable() bool {
+	return c == MVCCConflict || c == EndorsementMismatch || c == Timeout
+}
+
+// ClassifyError returns the class of an error returned by the SDK when submitting a transaction
+func ClassifyError(err error) ErrorClass {
+	if errors.Cause(err) == context.DeadlineExceeded {
+		return Timeout
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return UnknownError
+	}
+
+	switch s.Group {
+	case status.EventServerStatus:
+		switch peer.TxValidationCode(s.Code) {
+		case peer.TxValidationCode_MVCC_READ_CONFLICT, peer.TxValidationCode_PHANTOM_READ_CONFLICT:
+			return MVCCConflict
+		}
+	case status.EndorserClientStatus, status.OrdererClientStatus, status.ClientStatus:
+		switch status.Code(s.Code) {
+		case status.EndorsementMismatch:
+			return EndorsementMismatch
+		case status.Timeout:
+			return Timeout
+		case status.MultipleErrors:
+			return classifyMultipleErrors(s.Details)
+		}
+	case status.GRPCTransportStatus:
+		if codes.Code(s.Code) == codes.DeadlineExceeded {
+			return Timeout
+		}
+	case status.ChaincodeStatus, status.EndorserServerStatus:
+		return ChaincodeError
+	}
+
+	return UnknownError
+}
+
+// classifyMultipleErrors classifies the errors returned by several peers. A chaincode error
+// from any peer wins, since retrying would not help; otherwise the first known class is used.
+func classifyMultipleErrors(details []interface{}) ErrorClass {
+	class := UnknownError
+	for _, detail := range details {
+		err, ok := detail.(error)
+		if !ok {
+			continue
+		}
+		switch detailClass := ClassifyError(err); {
+		case detailClass == ChaincodeError:
+			return ChaincodeError
+		case class == UnknownError:
+			class = detailClass
+		}
+	}
+
+	return class
+}
+
+// Submitter submits transactions, it is implemented by gateway.Contract
+type Submitter interface {
+	SubmitTransaction(name string, args ...string) ([]byte, error)
+}
+
+// RetryPolicy retries the transactions that fail with a retryable error, waiting for a jittered
+// exponential backoff between attempts: a random duration between zero and InitialBackoff
+// doubled after each attempt, up to MaxBackoff.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a transaction is submitted, retries included
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// RetryTimeouts enables the retry of transactions that timed out. Only set it for transactions
+	// that can safely be committed twice, since a timed out transaction may have been committed.
+	RetryTimeouts bool
+	// OnRetry, if set, is called before waiting to retry a transaction
+	OnRetry func(name string, attempt int, class ErrorClass, err error, backoff time.Duration)
+}
+
+// DefaultRetryPolicy submits a transaction at most 5 times, waiting up to 100ms before the
+// first retry and up to 2s before the last one. It does not retry timeouts, set RetryTimeouts
+// to submit transactions that can safely be committed twice.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     2 * time.Second,
+}
+
+// Outcome is the outcome of a transaction submitted with a RetryPolicy
+type Outcome struct {
+	Result   []byte
+	Attempts int
+	// Err is the error of the last attempt, and Class its class. Err is nil when the
+	// transaction was committed. When Class is Timeout, the outcome is unknown: the
+	// transaction may still have been committed.
+	Err   error
+	Class ErrorClass
+}
+
+// jitter is the random source of the backoffs. rand.Rand is not safe for concurrent use,
+// and transactions are often submitted from several goroutines.
+var jitter = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// Submit submits a transaction, and retries it while it fails with a retryable error
+func (p RetryPolicy) Submit(contract Submitter, name string, args ...string) *Outcome {
+	outcome := &Outcome{}
+	backoff := p.InitialBackoff
+	for {
+		outcome.Attempts++
+		outcome.Result, outcome.Err = contract.SubmitTransaction(name, args...)
+		if outcome.Err == nil {
+			outcome.Class = UnknownError
+			return outcome
+		}
+
+		outcome.Class = ClassifyError(outcome.Err)
+		if !p.retryable(outcome.Class) || outcome.Attempts >= p.MaxAttempts {
+			return outcome
+		}
+
+		if backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+		wait := randomDuration(backoff)
+		if p.OnRetry != nil {
+			p.OnRetry(name, outcome.Attempts, outcome.Class, outcome.Err, wait)
+		}
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+// retryable returns whether the policy retries a transaction that failed with this class of error
+func (p RetryPolicy) retryable(class ErrorClass) bool {
+	return class.Retryable() && (class != Timeout || p.RetryTimeouts)
+}
+
+// randomDuration returns a random duration between zero and max
+func randomDuration(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	jitter.Lock()
+	defer jitter.Unlock()
+	return time.Duration(jitter.Int63n(int64(max) + 1))
+}
@@ -0,0 +1,212 @@
+/*
+Copyright IBM Corp. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package apputil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/multi"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	mvccConflictErr = status.New(status.EventServerStatus, int32(peer.TxValidationCode_MVCC_READ_CONFLICT), "MVCC_READ_CONFLICT", nil)
+	mismatchErr     = status.New(status.EndorserClientStatus, status.EndorsementMismatch.ToInt32(), "ProposalResponsePayloads do not match", nil)
+	timeoutErr      = status.New(status.ClientStatus, status.Timeout.ToInt32(), "request timed out", nil)
+	chaincodeErr    = status.New(status.ChaincodeStatus, 500, "the asset asset1 already exists", nil)
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected ErrorClass
+	}{
+		{
+			name:     "MVCC read conflict",
+			err:      errors.Wrap(mvccConflictErr, "failed to submit"),
+			expected: MVCCConflict,
+		},
+		{
+			name:     "phantom read conflict",
+			err:      errors.Wrap(status.New(status.EventServerStatus, int32(peer.TxValidationCode_PHANTOM_READ_CONFLICT), "PHANTOM_READ_CONFLICT", nil), "failed to submit"),
+			expected: MVCCConflict,
+		},
+		{
+			name:     "other validation code",
+			err:      errors.Wrap(status.New(status.EventServerStatus, int32(peer.TxValidationCode_ENDORSEMENT_POLICY_FAILURE), "ENDORSEMENT_POLICY_FAILURE", nil), "failed to submit"),
+			expected: UnknownError,
+		},
+		{
+			name:     "endorsement mismatch",
+			err:      errors.Wrap(mismatchErr, "failed to endorse"),
+			expected: EndorsementMismatch,
+		},
+		{
+			name:     "client timeout",
+			err:      errors.Wrap(timeoutErr, "failed to submit"),
+			expected: Timeout,
+		},
+		{
+			name:     "orderer timeout",
+			err:      errors.Wrap(status.New(status.OrdererClientStatus, status.Timeout.ToInt32(), "request timed out", nil), "failed to order"),
+			expected: Timeout,
+		},
+		{
+			name:     "gRPC deadline exceeded",
+			err:      errors.Wrap(status.New(status.GRPCTransportStatus, int32(codes.DeadlineExceeded), "context deadline exceeded", nil), "failed to endorse"),
+			expected: Timeout,
+		},
+		{
+			name:     "gRPC unavailable",
+			err:      errors.Wrap(status.New(status.GRPCTransportStatus, int32(codes.Unavailable), "connection refused", nil), "failed to endorse"),
+			expected: UnknownError,
+		},
+		{
+			name:     "context deadline exceeded",
+			err:      errors.Wrap(context.DeadlineExceeded, "failed to submit"),
+			expected: Timeout,
+		},
+		{
+			name:     "chaincode error",
+			err:      errors.Wrap(chaincodeErr, "failed to endorse"),
+			expected: ChaincodeError,
+		},
+		{
+			name:     "endorser server error",
+			err:      errors.Wrap(status.New(status.EndorserServerStatus, 500, "chaincode not found", nil), "failed to endorse"),
+			expected: ChaincodeError,
+		},
+		{
+			name:     "error without status",
+			err:      errors.New("wallet not found"),
+			expected: UnknownError,
+		},
+		{
+			name:     "multiple errors with a chaincode error",
+			err:      errors.Wrap(multi.New(mismatchErr, chaincodeErr), "failed to endorse"),
+			expected: ChaincodeError,
+		},
+		{
+			name:     "multiple errors use the first known class",
+			err:      errors.Wrap(multi.New(errors.New("connection reset"), timeoutErr, mismatchErr), "failed to endorse"),
+			expected: Timeout,
+		},
+		{
+			name:     "multiple unknown errors",
+			err:      multi.New(errors.New("connection reset"), errors.New("connection refused")),
+			expected: UnknownError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, ClassifyError(test.err))
+		})
+	}
+}
+
+// fakeSubmitter fails the transactions it submits with errs in turn, and succeeds once it runs out of errors
+type fakeSubmitter struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeSubmitter) SubmitTransaction(name string, args ...string) ([]byte, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return []byte("result"), nil
+}
+
+// retries records the calls to OnRetry
+type retries struct {
+	classes  []ErrorClass
+	backoffs []time.Duration
+}
+
+func (r *retries) onRetry(name string, attempt int, class ErrorClass, err error, backoff time.Duration) {
+	r.classes = append(r.classes, class)
+	r.backoffs = append(r.backoffs, backoff)
+}
+
+func TestSubmitRetriesUntilCommitted(t *testing.T) {
+	contract := &fakeSubmitter{errs: []error{mvccConflictErr, mismatchErr, timeoutErr}}
+	var r retries
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, RetryTimeouts: true, OnRetry: r.onRetry}
+
+	outcome := policy.Submit(contract, "UpdateAsset", "asset1")
+	require.NoError(t, outcome.Err)
+	require.Equal(t, []byte("result"), outcome.Result)
+	require.Equal(t, 4, outcome.Attempts)
+	require.Equal(t, UnknownError, outcome.Class)
+	require.Equal(t, []ErrorClass{MVCCConflict, EndorsementMismatch, Timeout}, r.classes)
+}
+
+func TestSubmitStopsAtMaxAttempts(t *testing.T) {
+	contract := &fakeSubmitter{errs: []error{mvccConflictErr, mvccConflictErr, mvccConflictErr, mvccConflictErr}}
+	var r retries
+	policy := RetryPolicy{MaxAttempts: 3, OnRetry: r.onRetry}
+
+	outcome := policy.Submit(contract, "UpdateAsset", "asset1")
+	require.Equal(t, mvccConflictErr, outcome.Err)
+	require.Equal(t, MVCCConflict, outcome.Class)
+	require.Equal(t, 3, outcome.Attempts)
+	require.Equal(t, 3, contract.calls)
+	require.Len(t, r.classes, 2)
+}
+
+func TestSubmitCapsBackoff(t *testing.T) {
+	contract := &fakeSubmitter{errs: []error{mvccConflictErr, mvccConflictErr, mvccConflictErr, mvccConflictErr, mvccConflictErr}}
+	var r retries
+	policy := RetryPolicy{MaxAttempts: 6, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond, OnRetry: r.onRetry}
+
+	outcome := policy.Submit(contract, "UpdateAsset", "asset1")
+	require.NoError(t, outcome.Err)
+	require.Len(t, r.backoffs, 5)
+	require.LessOrEqual(t, int64(r.backoffs[0]), int64(time.Millisecond))
+	for _, backoff := range r.backoffs {
+		require.GreaterOrEqual(t, int64(backoff), int64(0))
+		require.LessOrEqual(t, int64(backoff), int64(2*time.Millisecond))
+	}
+}
+
+func TestSubmitDoesNotRetryChaincodeError(t *testing.T) {
+	contract := &fakeSubmitter{errs: []error{errors.Wrap(chaincodeErr, "failed to endorse")}}
+	var r retries
+	policy := RetryPolicy{MaxAttempts: 5, OnRetry: r.onRetry}
+
+	outcome := policy.Submit(contract, "CreateAsset", "asset1")
+	require.EqualError(t, outcome.Err, "failed to endorse: "+chaincodeErr.Error())
+	require.Equal(t, ChaincodeError, outcome.Class)
+	require.Equal(t, 1, outcome.Attempts)
+	require.Empty(t, r.classes)
+}
+
+func TestSubmitRetriesTimeoutsOnlyWhenEnabled(t *testing.T) {
+	contract := &fakeSubmitter{errs: []error{timeoutErr}}
+	policy := DefaultRetryPolicy
+	policy.InitialBackoff = 0
+
+	outcome := policy.Submit(contract, "CreateAsset", "asset1")
+	require.Equal(t, timeoutErr, outcome.Err)
+	require.Equal(t, Timeout, outcome.Class)
+	require.Equal(t, 1, outcome.Attempts)
+
+	contract = &fakeSubmitter{errs: []error{timeoutErr}}
+	policy.RetryTimeouts = true
+	outcome = policy.Submit(contract, "UpdateAsset", "asset1")
+	require.NoError(t, outcome.Err)
+	require.Equal(t, 2, outcome.Attempts)
+}